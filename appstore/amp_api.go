@@ -3,8 +3,10 @@ package appstore
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"golang.org/x/net/html"
 )
@@ -12,15 +14,17 @@ import (
 type AppId int64
 type Token string
 
-/// Get the JWT that is used to access Apple's amp-api.apps.apple.com API.
-func GetToken(ctx context.Context, client *http.Client) (Token, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://apps.apple.com/us/developer/apple/id284417353", nil)
+// Get the JWT that is used to access Apple's amp-api.apps.apple.com API from the website
+// of a storefront, e.g. "us" or "gb".
+func GetToken(ctx context.Context, client *Client, country string) (Token, error) {
+	tokenUrl := fmt.Sprintf("%s/%s/developer/apple/id284417353", client.WebsiteUrl, strings.ToLower(country))
+	req, err := http.NewRequestWithContext(ctx, "GET", tokenUrl, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Add("User-Agent", fakeUserAgent)
 
-	resp, err := client.Do(req)
+	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -1,14 +1,60 @@
 package appstore
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"strconv"
 	"strings"
 )
 
 const fakeUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36 Edg/87.0.664.75"
 
+// The addresses of the App Store website, the iTunes API and the API used by the App Store
+// website
+const (
+	DefaultWebsiteUrl = "https://apps.apple.com"
+	DefaultLookupUrl  = "https://itunes.apple.com"
+	DefaultAmpApiUrl  = "https://amp-api.apps.apple.com"
+)
+
+// The default maximum number of chunks of IDs that are scraped at the same time
+const DefaultMaxConcurrentChunks = 4
+
+// A client for the App Store. The addresses can be changed to point the scrapers at
+// different servers, e.g. fake ones for testing. It should not be changed once it is in
+// use.
+type Client struct {
+	HTTPClient *http.Client
+
+	WebsiteUrl string
+	LookupUrl  string
+	AmpApiUrl  string
+
+	// The maximum number of chunks of a long list of IDs that are scraped at the same time
+	MaxConcurrentChunks int
+}
+
+// Create a client for the real App Store that makes its requests with httpClient
+func NewClient(httpClient *http.Client) *Client {
+	return &Client{
+		HTTPClient:          httpClient,
+		WebsiteUrl:          DefaultWebsiteUrl,
+		LookupUrl:           DefaultLookupUrl,
+		AmpApiUrl:           DefaultAmpApiUrl,
+		MaxConcurrentChunks: DefaultMaxConcurrentChunks,
+	}
+}
+
 var ErrRateLimited = errors.New("Rate-limited")
+var ErrAppNotFound = errors.New("App not found")
+
+// Apple's APIs want a locale made of the language and the storefront, e.g. en-gb for
+// English in the UK storefront. The separator is - for amp-api and _ for the iTunes API.
+func locale(country string, language string, separator string) string {
+	return strings.ToLower(language) + separator + strings.ToLower(country)
+}
 
 func commaSeparatedAppIDs(appIds []AppId) string {
 	var sb strings.Builder
@@ -20,3 +66,31 @@ func commaSeparatedAppIDs(appIds []AppId) string {
 	}
 	return sb.String()
 }
+
+// Values in the iTunes RSS feeds are wrapped in an object
+type label struct {
+	Label string `json:"label"`
+}
+
+// The iTunes RSS feeds have a single object rather than an array if there is only one
+// entry
+type oneOrMany[T any] []T
+
+func (o *oneOrMany[T]) UnmarshalJSON(p []byte) error {
+	p = bytes.TrimSpace(p)
+	if bytes.HasPrefix(p, []byte("{")) {
+		var one T
+		if err := json.Unmarshal(p, &one); err != nil {
+			return err
+		}
+		*o = oneOrMany[T]{one}
+		return nil
+	}
+
+	var many []T
+	if err := json.Unmarshal(p, &many); err != nil {
+		return err
+	}
+	*o = many
+	return nil
+}
@@ -0,0 +1,292 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// The details of an app that are only in the amp-api catalog, which the App Store website
+// uses, and not in the iTunes lookup API
+type CatalogDetails struct {
+	PrivacyNutritionLabels PrivacyNutritionLabels `json:"privacy_nutrition_labels"`
+	// Newest first
+	VersionHistory []Version `json:"version_history"`
+	Histogram      Histogram `json:"histogram"`
+	// The Top In-App Purchases on the app's page
+	InAppPurchases []InAppPurchase `json:"in_app_purchases"`
+	// What could not be read from the catalog, e.g. a malformed ratings histogram, which
+	// is left empty rather than failing the other apps that were scraped with the app
+	Problems []string `json:"problems,omitempty"`
+}
+
+type Version struct {
+	Version      string    `json:"version"`
+	ReleaseNotes string    `json:"release_notes"`
+	Released     null.Time `json:"released"`
+}
+
+type InAppPurchase struct {
+	// The product ID, e.g. com.example.app.premium
+	Id    string  `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+	// The price in the storefront's format, e.g. $4.99 or 4,99 €
+	FormattedPrice string `json:"formatted_price"`
+	Currency       string `json:"currency"`
+	Subscription   bool   `json:"subscription"`
+	// How often a subscription renews as an ISO 8601 duration, e.g. P1M for monthly
+	SubscriptionPeriod string `json:"subscription_period"`
+}
+
+// The number of ratings with each number of stars
+type Histogram struct {
+	Stars1 int64 `json:"1"`
+	Stars2 int64 `json:"2"`
+	Stars3 int64 `json:"3"`
+	Stars4 int64 `json:"4"`
+	Stars5 int64 `json:"5"`
+}
+
+// Scrape the amp-api catalog details of the apps in a storefront, e.g. "us" or "gb". If
+// the app ID is not found, then it is not returned in the map. Any number of apps can be
+// scraped at once.
+func ScrapeCatalog(ctx context.Context, client *Client, token Token, appIds []AppId, country string, language string) (map[AppId]CatalogDetails, error) {
+	return scrapeChunks(ctx, client, appIds, MaxCatalogIds, func(ctx context.Context, appIds []AppId) (map[AppId]CatalogDetails, error) {
+		return scrapeCatalog(ctx, client, token, appIds, country, language)
+	})
+}
+
+// Scrape the Top In-App Purchases of the apps in a storefront, with their prices in the
+// currency of the storefront. If the app ID is not found, then it is not returned in the
+// map.
+func ScrapeInAppPurchases(ctx context.Context, client *Client, token Token, appIds []AppId, country string, language string) (map[AppId][]InAppPurchase, error) {
+	catalog, err := ScrapeCatalog(ctx, client, token, appIds, country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	inAppPurchases := make(map[AppId][]InAppPurchase, len(catalog))
+	for appId, app := range catalog {
+		inAppPurchases[appId] = app.InAppPurchases
+	}
+
+	return inAppPurchases, nil
+}
+
+func scrapeCatalog(ctx context.Context, client *Client, token Token, appIds []AppId, country string, language string) (map[AppId]CatalogDetails, error) {
+	catalogUrl := fmt.Sprintf("%s/v1/catalog/%s/apps", client.AmpApiUrl, strings.ToUpper(country))
+	req, err := http.NewRequestWithContext(ctx, "GET", catalogUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("User-Agent", fakeUserAgent)
+	req.Header.Add("Origin", client.WebsiteUrl)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	// Set the query parameters
+	q := req.URL.Query()
+	q.Add("platform", "web")
+	q.Add("l", locale(country, language, "-"))
+	q.Add("ids", commaSeparatedAppIDs(appIds))
+	q.Add("extend", "privacyDetails,versionHistory")
+	q.Add("include", "top-in-apps")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		} else if resp.StatusCode == http.StatusUnauthorized {
+			return nil, ErrUnauthorized
+		} else {
+			return nil, fmt.Errorf("ScrapeCatalog: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response catalogResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make(map[AppId]CatalogDetails)
+
+	for _, app := range response.Data {
+		apps[app.Id] = app.convert()
+	}
+
+	return apps, nil
+}
+
+type catalogResponse struct {
+	Data []rawCatalogApp `json:"data"`
+}
+
+type rawCatalogApp struct {
+	Id         AppId `json:"id,string"`
+	Attributes struct {
+		PrivacyDetails struct {
+			PrivacyTypes []rawPrivacyType `json:"privacyTypes"`
+		} `json:"privacyDetails"`
+
+		UserRating struct {
+			// From one star to five stars
+			RatingCountList []int64 `json:"ratingCountList"`
+		} `json:"userRating"`
+
+		// Keyed by platform, e.g. ios or osx
+		PlatformAttributes map[string]rawPlatformAttributes `json:"platformAttributes"`
+	} `json:"attributes"`
+
+	Relationships struct {
+		TopInApps struct {
+			Data []rawInAppPurchase `json:"data"`
+		} `json:"top-in-apps"`
+	} `json:"relationships"`
+}
+
+// iPhone and iPad apps are under ios, and Mac apps under osx. Apps that are only on other
+// platforms fall back to the first one of these, so that the same one is always picked.
+var catalogPlatforms = []string{"ios", "osx", "appletvos", "watchos"}
+
+func (app *rawCatalogApp) convert() CatalogDetails {
+	var details CatalogDetails
+
+	for _, privacyType := range app.Attributes.PrivacyDetails.PrivacyTypes {
+		details.PrivacyNutritionLabels = append(details.PrivacyNutritionLabels, privacyType.convert())
+	}
+
+	counts := app.Attributes.UserRating.RatingCountList
+	if len(counts) == 5 {
+		details.Histogram = Histogram{
+			Stars1: counts[0],
+			Stars2: counts[1],
+			Stars3: counts[2],
+			Stars4: counts[3],
+			Stars5: counts[4],
+		}
+	} else if len(counts) != 0 {
+		details.Problems = append(details.Problems, fmt.Sprintf("expected 5 rating counts, got %d", len(counts)))
+	}
+
+	for _, rv := range app.platformAttributes().VersionHistory {
+		version, err := rv.convert()
+		if err != nil {
+			details.Problems = append(details.Problems, err.Error())
+		}
+		details.VersionHistory = append(details.VersionHistory, version)
+	}
+
+	for _, inApp := range app.Relationships.TopInApps.Data {
+		details.InAppPurchases = append(details.InAppPurchases, inApp.convert())
+	}
+
+	return details
+}
+
+func (app *rawCatalogApp) platformAttributes() rawPlatformAttributes {
+	for _, platform := range catalogPlatforms {
+		if attributes, ok := app.Attributes.PlatformAttributes[platform]; ok {
+			return attributes
+		}
+	}
+
+	platforms := make([]string, 0, len(app.Attributes.PlatformAttributes))
+	for platform := range app.Attributes.PlatformAttributes {
+		platforms = append(platforms, platform)
+	}
+	if len(platforms) == 0 {
+		return rawPlatformAttributes{}
+	}
+
+	sort.Strings(platforms)
+	return app.Attributes.PlatformAttributes[platforms[0]]
+}
+
+type rawPlatformAttributes struct {
+	VersionHistory []rawVersion `json:"versionHistory"`
+}
+
+type rawInAppPurchase struct {
+	Attributes struct {
+		OfferName      string `json:"offerName"`
+		Name           string `json:"name"`
+		IsSubscription bool   `json:"isSubscription"`
+		Offers         []struct {
+			Price                       float64 `json:"price"`
+			PriceFormatted              string  `json:"priceFormatted"`
+			CurrencyCode                string  `json:"currencyCode"`
+			RecurringSubscriptionPeriod string  `json:"recurringSubscriptionPeriod"`
+		} `json:"offers"`
+	} `json:"attributes"`
+}
+
+func (ri *rawInAppPurchase) convert() InAppPurchase {
+	inApp := InAppPurchase{
+		Id:           ri.Attributes.OfferName,
+		Name:         ri.Attributes.Name,
+		Subscription: ri.Attributes.IsSubscription,
+	}
+
+	// There is only ever one offer for the storefront
+	if len(ri.Attributes.Offers) > 0 {
+		offer := ri.Attributes.Offers[0]
+		inApp.Price = offer.Price
+		inApp.FormattedPrice = offer.PriceFormatted
+		inApp.Currency = offer.CurrencyCode
+		inApp.SubscriptionPeriod = offer.RecurringSubscriptionPeriod
+	}
+
+	return inApp
+}
+
+type rawVersion struct {
+	VersionDisplay   string `json:"versionDisplay"`
+	ReleaseNotes     string `json:"releaseNotes"`
+	ReleaseDate      string `json:"releaseDate"`
+	ReleaseTimestamp string `json:"releaseTimestamp"`
+}
+
+// Convert the version, which is returned without the release date if it cannot be parsed
+func (rv *rawVersion) convert() (Version, error) {
+	version := Version{
+		Version:      rv.VersionDisplay,
+		ReleaseNotes: rv.ReleaseNotes,
+	}
+
+	// The timestamp is more precise, but older versions only have the date
+	if rv.ReleaseTimestamp != "" {
+		released, err := time.Parse(time.RFC3339, rv.ReleaseTimestamp)
+		if err != nil {
+			return version, fmt.Errorf("version %s: %w", rv.VersionDisplay, err)
+		}
+		version.Released = null.TimeFrom(released)
+	} else if rv.ReleaseDate != "" {
+		released, err := time.Parse("2006-01-02", rv.ReleaseDate)
+		if err != nil {
+			return version, fmt.Errorf("version %s: %w", rv.VersionDisplay, err)
+		}
+		version.Released = null.TimeFrom(released)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,144 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestScrapeCatalog(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	token, err := GetToken(context.Background(), client, "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const ClockId = AppId(1584215688)
+
+	catalog, err := ScrapeCatalog(context.Background(), client, token, []AppId{ClockId}, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := catalog[ClockId]
+	assert.NotEmpty(t, clock.PrivacyNutritionLabels)
+	assert.NotEmpty(t, clock.VersionHistory)
+	assert.Positive(t, clock.Histogram.Stars5)
+
+	for i, version := range clock.VersionHistory {
+		assert.NotEmpty(t, version.Version)
+		assert.True(t, version.Released.Valid)
+		if i > 0 {
+			assert.False(t, version.Released.Time.After(clock.VersionHistory[i-1].Released.Time), "versions should be newest first")
+		}
+	}
+}
+
+func TestScrapeInAppPurchases(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	token, err := GetToken(context.Background(), client, "gb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const DuolingoId = AppId(570060128)
+
+	inAppPurchases, err := ScrapeInAppPurchases(context.Background(), client, token, []AppId{DuolingoId}, "gb", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, inAppPurchases[DuolingoId])
+	for _, inApp := range inAppPurchases[DuolingoId] {
+		assert.NotEmpty(t, inApp.Name)
+		assert.Positive(t, inApp.Price)
+		assert.Equal(t, "GBP", inApp.Currency)
+		assert.Contains(t, inApp.FormattedPrice, "£")
+	}
+}
+
+func TestCatalogConvert(t *testing.T) {
+	var app rawCatalogApp
+	err := json.Unmarshal([]byte(`{
+		"id": "1584215688",
+		"attributes": {
+			"privacyDetails": {"privacyTypes": [{"identifier": "DATA_NOT_COLLECTED"}]},
+			"userRating": {"value": 4.2, "ratingCount": 150, "ratingCountList": [10, 5, 15, 20, 100]},
+			"platformAttributes": {
+				"ios": {
+					"versionHistory": [
+						{"versionDisplay": "1.1", "releaseNotes": "Bug fixes", "releaseDate": "2022-05-01", "releaseTimestamp": "2022-05-01T17:00:50Z"},
+						{"versionDisplay": "1.0", "releaseDate": "2021-09-20"}
+					]
+				}
+			}
+		},
+		"relationships": {
+			"top-in-apps": {
+				"data": [
+					{"id": "1", "type": "in-apps", "attributes": {"offerName": "com.apple.clock.alarms", "name": "More Alarms", "isSubscription": false, "offers": [{"price": 0.99, "priceFormatted": "$0.99", "currencyCode": "USD"}]}},
+					{"id": "2", "type": "in-apps", "attributes": {"offerName": "com.apple.clock.plus", "name": "Clock+", "isSubscription": true, "offers": [{"price": 4.99, "priceFormatted": "$4.99", "currencyCode": "USD", "recurringSubscriptionPeriod": "P1M"}]}}
+				]
+			}
+		}
+	}`), &app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details := app.convert()
+
+	assert.Equal(t, CatalogDetails{
+		PrivacyNutritionLabels: PrivacyNutritionLabels{{Identifier: "DATA_NOT_COLLECTED"}},
+		VersionHistory: []Version{
+			{Version: "1.1", ReleaseNotes: "Bug fixes", Released: null.TimeFrom(time.Date(2022, time.May, 1, 17, 0, 50, 0, time.UTC))},
+			{Version: "1.0", Released: null.TimeFrom(time.Date(2021, time.September, 20, 0, 0, 0, 0, time.UTC))},
+		},
+		Histogram: Histogram{Stars1: 10, Stars2: 5, Stars3: 15, Stars4: 20, Stars5: 100},
+		InAppPurchases: []InAppPurchase{
+			{Id: "com.apple.clock.alarms", Name: "More Alarms", Price: 0.99, FormattedPrice: "$0.99", Currency: "USD"},
+			{Id: "com.apple.clock.plus", Name: "Clock+", Price: 4.99, FormattedPrice: "$4.99", Currency: "USD", Subscription: true, SubscriptionPeriod: "P1M"},
+		},
+	}, details)
+}
+
+func TestCatalogConvertProblems(t *testing.T) {
+	var app rawCatalogApp
+	err := json.Unmarshal([]byte(`{
+		"id": "1584215688",
+		"attributes": {
+			"userRating": {"value": 4.2, "ratingCount": 150, "ratingCountList": [10, 5, 15, 120]},
+			"platformAttributes": {
+				"watchos": {"versionHistory": [{"versionDisplay": "2.0", "releaseDate": "2022-05-01"}]},
+				"appletvos": {
+					"versionHistory": [
+						{"versionDisplay": "1.1", "releaseTimestamp": "yesterday"},
+						{"versionDisplay": "1.0", "releaseDate": "2021-09-20"}
+					]
+				}
+			}
+		}
+	}`), &app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The app is kept without the parts that cannot be read
+	details := app.convert()
+	assert.Equal(t, Histogram{}, details.Histogram)
+	assert.Len(t, details.Problems, 2)
+
+	// The same platform is always picked
+	if assert.Len(t, details.VersionHistory, 2) {
+		assert.Equal(t, Version{Version: "1.1"}, details.VersionHistory[0])
+		assert.True(t, details.VersionHistory[1].Released.Valid)
+	}
+}
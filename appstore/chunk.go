@@ -0,0 +1,71 @@
+package appstore
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// The number of app (or bundle) IDs that Apple's APIs accept in one request. Longer lists are split
+// into chunks of this size, which are scraped at the same time.
+const (
+	MaxLookupIds  = 100
+	MaxCatalogIds = 100
+)
+
+// Split the IDs into chunks, scrape at most client.MaxConcurrentChunks of them at the
+// same time and merge the results. The first error stops the other chunks.
+func scrapeChunks[K comparable, T any](ctx context.Context, client *Client, ids []K, chunkSize int, scrapeChunk func(ctx context.Context, ids []K) (map[K]T, error)) (map[K]T, error) {
+	if len(ids) <= chunkSize {
+		return scrapeChunk(ctx, ids)
+	}
+
+	maxConcurrent := client.MaxConcurrentChunks
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrentChunks
+	}
+
+	errgrp, ctx := errgroup.WithContext(ctx)
+	connectionLimit := make(chan struct{}, maxConcurrent)
+
+	var mu sync.Mutex
+	result := make(map[K]T, len(ids))
+
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		errgrp.Go(func() error {
+			// Limit the number of concurrent connections
+			select {
+			case connectionLimit <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-connectionLimit }()
+
+			chunkResult, err := scrapeChunk(ctx, chunk)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for id, v := range chunkResult {
+				result[id] = v
+			}
+
+			return nil
+		})
+	}
+
+	if err := errgrp.Wait(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
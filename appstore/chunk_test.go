@@ -0,0 +1,74 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeDetailsChunks(t *testing.T) {
+	var mu sync.Mutex
+	requests, concurrent, maxConcurrent := 0, 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		concurrent++
+		if concurrent > maxConcurrent {
+			maxConcurrent = concurrent
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			concurrent--
+			mu.Unlock()
+		}()
+
+		ids := strings.Split(r.URL.Query().Get("id"), ",")
+		if len(ids) > MaxLookupIds {
+			http.Error(w, "Too many IDs", http.StatusBadRequest)
+			return
+		}
+
+		// Give the other chunks a chance to run at the same time
+		time.Sleep(10 * time.Millisecond)
+
+		// Every other app does not exist
+		results := []interface{}{}
+		for _, id := range ids {
+			appId, _ := strconv.ParseInt(id, 10, 64)
+			if appId%2 == 0 {
+				results = append(results, map[string]interface{}{"wrapperType": "software", "trackId": appId})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"resultCount": len(results), "results": results})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	client.LookupUrl = server.URL
+
+	appIds := make([]AppId, 0, 10*MaxLookupIds+1)
+	for i := 1; i <= 10*MaxLookupIds+1; i++ {
+		appIds = append(appIds, AppId(i))
+	}
+
+	details, err := ScrapeDetails(context.Background(), client, appIds, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, details, 10*MaxLookupIds/2)
+	assert.Contains(t, details, AppId(10*MaxLookupIds))
+	assert.Equal(t, 11, requests)
+	assert.LessOrEqual(t, maxConcurrent, client.MaxConcurrentChunks)
+}
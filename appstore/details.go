@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"gopkg.in/guregu/null.v4"
 )
@@ -47,21 +48,82 @@ type Details struct {
 	SupportedDevices      []string   `json:"supported_devices"`
 }
 
-func ScrapeDetails(ctx context.Context, client *http.Client, appIds []AppId) (map[AppId]Details, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://itunes.apple.com/lookup", nil)
+// Scrape the details of the apps in a storefront, e.g. "us" or "gb". The language is an
+// ISO 639-1 code, e.g. "en", but Apple only supports some combinations of storefront and
+// language and falls back to the default language of the storefront otherwise. Any number
+// of apps can be scraped at once.
+func ScrapeDetails(ctx context.Context, client *Client, appIds []AppId, country string, language string) (map[AppId]Details, error) {
+	return scrapeChunks(ctx, client, appIds, MaxLookupIds, func(ctx context.Context, appIds []AppId) (map[AppId]Details, error) {
+		return scrapeDetails(ctx, client, appIds, country, language)
+	})
+}
+
+func scrapeDetails(ctx context.Context, client *Client, appIds []AppId, country string, language string) (map[AppId]Details, error) {
+	detailsList, err := lookup(ctx, client, "id", commaSeparatedAppIDs(appIds), country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	// If an ID does not exist, Apple's API just ignores it
+	result := make(map[AppId]Details)
+	for _, details := range detailsList {
+		result[details.AppId] = details
+	}
+
+	return result, nil
+}
+
+// Scrape the details of the apps with the bundle IDs, e.g. com.apple.mobiletimer, like
+// ScrapeDetails does. The bundle IDs are matched without regard to case, and the map is
+// keyed by the bundle IDs as they were given.
+func ScrapeDetailsByBundleId(ctx context.Context, client *Client, bundleIds []string, country string, language string) (map[string]Details, error) {
+	return scrapeChunks(ctx, client, bundleIds, MaxLookupIds, func(ctx context.Context, bundleIds []string) (map[string]Details, error) {
+		return scrapeDetailsByBundleId(ctx, client, bundleIds, country, language)
+	})
+}
+
+func scrapeDetailsByBundleId(ctx context.Context, client *Client, bundleIds []string, country string, language string) (map[string]Details, error) {
+	detailsList, err := lookup(ctx, client, "bundleId", strings.Join(bundleIds, ","), country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	byBundleId := make(map[string]Details, len(detailsList))
+	for _, details := range detailsList {
+		byBundleId[strings.ToLower(details.BundleId)] = details
+	}
+
+	// If a bundle ID does not exist, Apple's API just ignores it
+	result := make(map[string]Details)
+	for _, bundleId := range bundleIds {
+		if details, ok := byBundleId[strings.ToLower(bundleId)]; ok {
+			result[bundleId] = details
+		}
+	}
+
+	return result, nil
+}
+
+// Look up apps by a comma separated list of IDs, where key is the query parameter for
+// the kind of ID
+func lookup(ctx context.Context, client *Client, key string, ids string, country string, language string) ([]Details, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", client.LookupUrl+"/lookup", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	q := req.URL.Query()
 	q.Add("entity", "software")
-	q.Add("id", commaSeparatedAppIDs(appIds))
+	q.Add(key, ids)
+	q.Add("country", strings.ToLower(country))
+	q.Add("lang", locale(country, language, "_"))
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := client.Do(req)
+	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusTooManyRequests {
@@ -71,7 +133,6 @@ func ScrapeDetails(ctx context.Context, client *http.Client, appIds []AppId) (ma
 		}
 	}
 
-	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -83,23 +144,18 @@ func ScrapeDetails(ctx context.Context, client *http.Client, appIds []AppId) (ma
 		return nil, err
 	}
 
-	// If an ID does not exist, Apple's API just ignores it
-	result := make(map[AppId]Details)
-	detailsList, err := lookupResponse.ToDetails()
-	if err != nil {
-		return nil, err
-	}
-	for _, details := range detailsList {
-		result[details.AppId] = details
-	}
-
-	return result, err
+	return lookupResponse.ToDetails()
 }
 
 func (lr *lookupResponse) ToDetails() ([]Details, error) {
 	detailsList := make([]Details, 0, lr.ResultCount)
 
 	for _, result := range lr.Results {
+		// Lookups on developers return the developers themselves as well as their apps
+		if result.WrapperType != "" && result.WrapperType != "software" {
+			continue
+		}
+
 		var icon string
 		if result.ArtworkURL512 != "" {
 			icon = result.ArtworkURL512
@@ -0,0 +1,25 @@
+package appstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestScrapeDetailsByBundleId(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	details, err := ScrapeDetailsByBundleId(context.Background(), client, []string{"com.apple.MobileTimer", "com.example.missing"}, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, details, 1)
+
+	clock := details["com.apple.MobileTimer"]
+	assert.Equal(t, AppId(1584215688), clock.AppId)
+	assert.Equal(t, "Clock", clock.Title)
+}
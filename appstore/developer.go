@@ -0,0 +1,134 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The most results that Apple returns for a lookup. The limit is on the whole response,
+// not on each developer, so a lookup on several developers can be cut short.
+const maxDeveloperApps = 200
+
+// The number of developer IDs that are looked up in one request. This is lower than
+// MaxLookupIds, as each developer can have up to 200 apps.
+const MaxDeveloperIds = 10
+
+// Scrape every app of each of the developers in a storefront, e.g. "us" or "gb", with the
+// language used like in ScrapeDetails. The developer IDs are the same as
+// Details.DeveloperId. Developers that do not exist are left
+// out of the result, and developers without any apps in the storefront have no apps. The
+// developers are looked up MaxDeveloperIds at a time, and a lookup that reaches the limit
+// on the number of results is split in two and tried again. Apple only returns the first
+// 200 apps of a single developer.
+func ScrapeDeveloperApps(ctx context.Context, client *Client, developerIds []int64, country string, language string) (map[int64][]Details, error) {
+	return scrapeChunks(ctx, client, developerIds, MaxDeveloperIds, func(ctx context.Context, developerIds []int64) (map[int64][]Details, error) {
+		return scrapeDeveloperApps(ctx, client, developerIds, country, language)
+	})
+}
+
+func scrapeDeveloperApps(ctx context.Context, client *Client, developerIds []int64, country string, language string) (map[int64][]Details, error) {
+	result, truncated, err := lookupDeveloperApps(ctx, client, developerIds, country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	if !truncated || len(developerIds) == 1 {
+		return result, nil
+	}
+
+	// Some of the apps are missing, so look up each half of the developers on its own
+	half := len(developerIds) / 2
+	result, err = scrapeDeveloperApps(ctx, client, developerIds[:half], country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	rest, err := scrapeDeveloperApps(ctx, client, developerIds[half:], country, language)
+	if err != nil {
+		return nil, err
+	}
+
+	for developerId, apps := range rest {
+		result[developerId] = apps
+	}
+
+	return result, nil
+}
+
+// Look up the apps of the developers in one request. Whether the response reached the
+// limit on the number of results, so that apps may be missing, is returned too.
+func lookupDeveloperApps(ctx context.Context, client *Client, developerIds []int64, country string, language string) (map[int64][]Details, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", client.LookupUrl+"/lookup", nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ids := make([]string, 0, len(developerIds))
+	for _, developerId := range developerIds {
+		ids = append(ids, strconv.FormatInt(developerId, 10))
+	}
+
+	q := req.URL.Query()
+	q.Add("entity", "software")
+	q.Add("id", strings.Join(ids, ","))
+	q.Add("country", strings.ToLower(country))
+	q.Add("lang", locale(country, language, "_"))
+	q.Add("limit", strconv.Itoa(maxDeveloperApps))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, false, ErrRateLimited
+		} else {
+			return nil, false, fmt.Errorf("ScrapeDeveloperApps: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var lookupResponse lookupResponse
+	if err := json.Unmarshal(body, &lookupResponse); err != nil {
+		return nil, false, err
+	}
+
+	requested := make(map[int64]bool, len(developerIds))
+	for _, developerId := range developerIds {
+		requested[developerId] = true
+	}
+
+	// Each developer comes first, followed by their apps
+	result := make(map[int64][]Details)
+	for _, r := range lookupResponse.Results {
+		if r.WrapperType == "artist" && requested[r.ArtistID] {
+			result[r.ArtistID] = []Details{}
+		}
+	}
+
+	apps, err := lookupResponse.ToDetails()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// In case an ID is of an app rather than a developer
+	for _, app := range apps {
+		if requested[app.DeveloperId] {
+			result[app.DeveloperId] = append(result[app.DeveloperId], app)
+		}
+	}
+
+	return result, len(lookupResponse.Results) >= maxDeveloperApps, nil
+}
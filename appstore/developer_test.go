@@ -0,0 +1,84 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestScrapeDeveloperApps(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	const Apple = int64(284417353)
+	const Missing = int64(1)
+
+	developers, err := ScrapeDeveloperApps(context.Background(), client, []int64{Apple, Missing}, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, developers, Missing)
+
+	apps := developers[Apple]
+	assert.NotEmpty(t, apps)
+
+	appIds := make([]AppId, 0, len(apps))
+	for _, app := range apps {
+		assert.Equal(t, Apple, app.DeveloperId)
+		appIds = append(appIds, app.AppId)
+	}
+
+	// Clock
+	assert.Contains(t, appIds, AppId(1584215688))
+}
+
+func TestScrapeDeveloperAppsLimit(t *testing.T) {
+	var mu sync.Mutex
+	var lookups []string
+
+	// Each developer has 150 apps, and the results are cut short at the limit like Apple does
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query().Get("id")
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		mu.Lock()
+		lookups = append(lookups, ids)
+		mu.Unlock()
+
+		results := []interface{}{}
+		for _, id := range strings.Split(ids, ",") {
+			developerId, _ := strconv.ParseInt(id, 10, 64)
+			results = append(results, map[string]interface{}{"wrapperType": "artist", "artistId": developerId})
+			for i := int64(1); i <= 150; i++ {
+				results = append(results, map[string]interface{}{"wrapperType": "software", "trackId": developerId*1000 + i, "artistId": developerId})
+			}
+		}
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"resultCount": len(results), "results": results})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	client.LookupUrl = server.URL
+
+	developers, err := ScrapeDeveloperApps(context.Background(), client, []int64{1, 2, 3}, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, developerId := range []int64{1, 2, 3} {
+		assert.Len(t, developers[developerId], 150)
+	}
+	assert.Equal(t, []string{"1,2,3", "1", "2,3", "2", "3"}, lookups)
+}
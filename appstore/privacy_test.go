@@ -2,22 +2,25 @@ package appstore
 
 import (
 	"context"
-	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
 )
 
 func TestPrivacyNutritionLabels(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
 	// First we need to get the token
-	token, err := GetToken(context.Background(), http.DefaultClient)
+	token, err := GetToken(context.Background(), client, "us")
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	const ClockId = AppId(1584215688)
 
-	labels, err := ScrapePrivacy(context.Background(), http.DefaultClient, token, []AppId{ClockId})
+	labels, err := ScrapePrivacy(context.Background(), client, token, []AppId{ClockId}, "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,200 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReviewSort string
+
+const (
+	SortMostRecent  ReviewSort = "mostrecent"
+	SortMostHelpful ReviewSort = "mosthelpful"
+)
+
+// The customer reviews feed only goes up to page 10, with 50 reviews on each page
+const maxReviewsPage = 10
+
+type Review struct {
+	Id        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Rating    int64     `json:"rating"`
+	Version   string    `json:"version"`
+	Author    string    `json:"author"`
+	AuthorUrl string    `json:"author_url"`
+	Date      time.Time `json:"date"`
+	VoteSum   int64     `json:"vote_sum"`
+	VoteCount int64     `json:"vote_count"`
+}
+
+// Scrape one page (from 1 to 10) of the reviews of an app in a storefront. The language
+// is an ISO 639-1 code, e.g. "en", for the parts of the feed that Apple translates; the
+// reviews are in whatever language they were written in. There are no reviews on the
+// pages after the last one.
+func ScrapeReviewsPage(ctx context.Context, client *Client, appId AppId, country string, language string, sort ReviewSort, page int) ([]Review, error) {
+	if page < 1 || page > maxReviewsPage {
+		return nil, fmt.Errorf("ScrapeReviewsPage: page must be between 1 and %d", maxReviewsPage)
+	}
+
+	reviewsUrl := fmt.Sprintf("%s/%s/rss/customerreviews/page=%d/id=%d/sortby=%s/json", client.LookupUrl, strings.ToLower(country), page, appId, sort)
+	req, err := http.NewRequestWithContext(ctx, "GET", reviewsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", fakeUserAgent)
+
+	q := req.URL.Query()
+	q.Add("l", strings.ToLower(language))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		} else {
+			return nil, fmt.Errorf("ScrapeReviewsPage: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseReviews(body)
+}
+
+// Scrape up to count reviews of an app in a storefront. If count is zero or negative, then
+// all the reviews are scraped, like for the Play Store, but Apple only gives out the latest
+// 500 reviews or so.
+func ScrapeReviews(ctx context.Context, client *Client, appId AppId, country string, language string, sort ReviewSort, count int) ([]Review, error) {
+	reviews := []Review{}
+
+	for page := 1; page <= maxReviewsPage && (count <= 0 || len(reviews) < count); page++ {
+		pageReviews, err := ScrapeReviewsPage(ctx, client, appId, country, language, sort, page)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pageReviews) == 0 {
+			break
+		}
+
+		reviews = append(reviews, pageReviews...)
+	}
+
+	if count > 0 && len(reviews) > count {
+		reviews = reviews[:count]
+	}
+
+	return reviews, nil
+}
+
+func parseReviews(body []byte) ([]Review, error) {
+	var response reviewsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	reviews := make([]Review, 0, len(response.Feed.Entry))
+	for _, entry := range response.Feed.Entry {
+		// The first entry used to be the app itself, which does not have a rating
+		if entry.Rating.Label == "" {
+			continue
+		}
+
+		review, err := entry.convert()
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, nil
+}
+
+type rawReview struct {
+	Author struct {
+		Name label `json:"name"`
+		Uri  label `json:"uri"`
+	} `json:"author"`
+	Updated   label `json:"updated"`
+	Rating    label `json:"im:rating"`
+	Version   label `json:"im:version"`
+	Id        label `json:"id"`
+	Title     label `json:"title"`
+	Content   label `json:"content"`
+	VoteSum   label `json:"im:voteSum"`
+	VoteCount label `json:"im:voteCount"`
+}
+
+func (rr *rawReview) convert() (Review, error) {
+	parseInt := func(field string, l label) (int64, error) {
+		if l.Label == "" {
+			return 0, nil
+		}
+
+		i, err := strconv.ParseInt(l.Label, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("review %s: %w", field, err)
+		}
+		return i, nil
+	}
+
+	id, err := parseInt("id", rr.Id)
+	if err != nil {
+		return Review{}, err
+	}
+
+	rating, err := parseInt("rating", rr.Rating)
+	if err != nil {
+		return Review{}, err
+	}
+
+	voteSum, err := parseInt("vote sum", rr.VoteSum)
+	if err != nil {
+		return Review{}, err
+	}
+
+	voteCount, err := parseInt("vote count", rr.VoteCount)
+	if err != nil {
+		return Review{}, err
+	}
+
+	date, err := time.Parse(time.RFC3339, rr.Updated.Label)
+	if err != nil {
+		return Review{}, fmt.Errorf("review date: %w", err)
+	}
+
+	return Review{
+		Id:        id,
+		Title:     rr.Title.Label,
+		Body:      rr.Content.Label,
+		Rating:    rating,
+		Version:   rr.Version.Label,
+		Author:    rr.Author.Name.Label,
+		AuthorUrl: rr.Author.Uri.Label,
+		Date:      date,
+		VoteSum:   voteSum,
+		VoteCount: voteCount,
+	}, nil
+}
+
+type reviewsResponse struct {
+	Feed struct {
+		Entry oneOrMany[rawReview] `json:"entry"`
+	} `json:"feed"`
+}
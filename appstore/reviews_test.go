@@ -0,0 +1,79 @@
+package appstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestScrapeReviews(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	// Clock
+	reviews, err := ScrapeReviews(context.Background(), client, AppId(1584215688), "us", "en", SortMostRecent, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, reviews)
+	assert.LessOrEqual(t, len(reviews), 80)
+
+	for i, review := range reviews {
+		assert.Positive(t, review.Id)
+		assert.True(t, 1 <= review.Rating && review.Rating <= 5)
+		assert.NotEmpty(t, review.Author)
+
+		if i > 0 {
+			assert.False(t, review.Date.After(reviews[i-1].Date), "reviews should be newest first")
+		}
+	}
+}
+
+func TestScrapeReviewsAll(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	// Clock, which has fewer reviews than Apple gives out, so the pages run out first
+	reviews, err := ScrapeReviews(context.Background(), client, AppId(1584215688), "us", "en", SortMostRecent, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Greater(t, len(reviews), 100)
+	assert.Less(t, len(reviews), maxReviewsPage*50)
+}
+
+func TestParseReviewsSingleEntry(t *testing.T) {
+	// With only one review, the entry is an object rather than an array
+	body := []byte(`{"feed":{"entry":{"author":{"uri":{"label":"https://itunes.apple.com/us/reviews/id1"},"name":{"label":"someone"}},"updated":{"label":"2022-05-01T10:00:00-07:00"},"im:rating":{"label":"4"},"im:version":{"label":"1.2"},"id":{"label":"8765"},"title":{"label":"Nice"},"content":{"label":"Does what it says","attributes":{"type":"text"}},"im:voteSum":{"label":"1"},"im:voteCount":{"label":"2"}}}}`)
+
+	reviews, err := parseReviews(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []Review{{
+		Id:        8765,
+		Title:     "Nice",
+		Body:      "Does what it says",
+		Rating:    4,
+		Version:   "1.2",
+		Author:    "someone",
+		AuthorUrl: "https://itunes.apple.com/us/reviews/id1",
+		Date:      time.Date(2022, time.May, 1, 10, 0, 0, 0, time.FixedZone("", -7*60*60)),
+		VoteSum:   1,
+		VoteCount: 2,
+	}}, reviews)
+}
+
+func TestParseReviewsNoReviews(t *testing.T) {
+	reviews, err := parseReviews([]byte(`{"feed":{"author":{"name":{"label":"iTunes Store"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, reviews)
+}
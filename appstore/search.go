@@ -0,0 +1,81 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The kinds of software that the iTunes Search API can search for
+type SearchEntity string
+
+const (
+	EntitySoftware     SearchEntity = "software"
+	EntityIpadSoftware SearchEntity = "iPadSoftware"
+	EntityMacSoftware  SearchEntity = "macSoftware"
+)
+
+// The iTunes Search API returns at most 200 results
+const MaxSearchLimit = 200
+
+// Search for apps in a storefront, e.g. "us" or "gb", with the iTunes Search API. The
+// language is used like in ScrapeDetails. Only iPhone apps are searched for unless other
+// entities are given. Apple does not give out more than 200 results, whatever the limit.
+func Search(ctx context.Context, client *Client, term string, country string, language string, limit int, entities ...SearchEntity) ([]Details, error) {
+	if limit < 1 || limit > MaxSearchLimit {
+		return nil, fmt.Errorf("Search: limit must be between 1 and %d", MaxSearchLimit)
+	}
+
+	if len(entities) == 0 {
+		entities = []SearchEntity{EntitySoftware}
+	}
+
+	entityStrings := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		entityStrings = append(entityStrings, string(entity))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", client.LookupUrl+"/search", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	q.Add("term", term)
+	q.Add("media", "software")
+	q.Add("entity", strings.Join(entityStrings, ","))
+	q.Add("country", strings.ToLower(country))
+	q.Add("lang", locale(country, language, "_"))
+	q.Add("limit", strconv.Itoa(limit))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		} else {
+			return nil, fmt.Errorf("Search: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var lookupResponse lookupResponse
+	if err := json.Unmarshal(body, &lookupResponse); err != nil {
+		return nil, err
+	}
+
+	return lookupResponse.ToDetails()
+}
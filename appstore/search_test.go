@@ -0,0 +1,32 @@
+package appstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestSearch(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	results, err := Search(context.Background(), client, "clock", "us", "en", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, results)
+	assert.LessOrEqual(t, len(results), 20)
+
+	for _, details := range results {
+		assert.Positive(t, details.AppId)
+		assert.NotEmpty(t, details.Title)
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	_, err := Search(context.Background(), nil, "clock", "us", "en", MaxSearchLimit+1)
+	assert.Error(t, err)
+}
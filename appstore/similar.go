@@ -0,0 +1,118 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The shelves of related apps on an app's page
+type SimilarView string
+
+const (
+	ViewCustomersAlsoBought SimilarView = "customers-also-bought-apps"
+	ViewMoreByDeveloper     SimilarView = "more-by-developer"
+)
+
+type SimilarApp struct {
+	AppId     AppId       `json:"app_id"`
+	Title     string      `json:"title"`
+	Developer string      `json:"developer"`
+	View      SimilarView `json:"view"`
+}
+
+// Scrape the apps that are related to an app in a storefront, e.g. "us" or "gb": the ones
+// that customers also bought, followed by the other apps of the developer. Returns
+// ErrAppNotFound if the app does not exist in the storefront.
+func ScrapeSimilar(ctx context.Context, client *Client, token Token, appId AppId, country string, language string) ([]SimilarApp, error) {
+	appUrl := fmt.Sprintf("%s/v1/catalog/%s/apps/%d", client.AmpApiUrl, strings.ToUpper(country), appId)
+	req, err := http.NewRequestWithContext(ctx, "GET", appUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("User-Agent", fakeUserAgent)
+	req.Header.Add("Origin", client.WebsiteUrl)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	views := []SimilarView{ViewCustomersAlsoBought, ViewMoreByDeveloper}
+	viewStrings := make([]string, 0, len(views))
+	for _, view := range views {
+		viewStrings = append(viewStrings, string(view))
+	}
+
+	q := req.URL.Query()
+	q.Add("platform", "web")
+	q.Add("l", locale(country, language, "-"))
+	q.Add("views", strings.Join(viewStrings, ","))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		} else if resp.StatusCode == http.StatusUnauthorized {
+			return nil, ErrUnauthorized
+		} else if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrAppNotFound
+		} else {
+			return nil, fmt.Errorf("ScrapeSimilar: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response similarResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Data) == 0 {
+		return nil, ErrAppNotFound
+	}
+
+	similarApps := []SimilarApp{}
+	for _, view := range views {
+		for _, app := range response.Data[0].Views[view].Data {
+			similarAppId, err := strconv.ParseInt(app.Id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ScrapeSimilar: %s: app ID: %w", view, err)
+			}
+
+			similarApps = append(similarApps, SimilarApp{
+				AppId:     AppId(similarAppId),
+				Title:     app.Attributes.Name,
+				Developer: app.Attributes.ArtistName,
+				View:      view,
+			})
+		}
+	}
+
+	return similarApps, nil
+}
+
+type similarResponse struct {
+	Data []struct {
+		Views map[SimilarView]struct {
+			Data []struct {
+				Id         string `json:"id"`
+				Attributes struct {
+					Name       string `json:"name"`
+					ArtistName string `json:"artistName"`
+				} `json:"attributes"`
+			} `json:"data"`
+		} `json:"views"`
+	} `json:"data"`
+}
@@ -0,0 +1,51 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestScrapeSimilar(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	token, err := GetToken(context.Background(), client, "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clock
+	similar, err := ScrapeSimilar(context.Background(), client, token, AppId(1584215688), "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, similar)
+
+	moreByApple := 0
+	for _, app := range similar {
+		assert.Positive(t, app.AppId)
+		assert.NotEmpty(t, app.Title)
+		if app.View == ViewMoreByDeveloper {
+			assert.Equal(t, "Apple", app.Developer)
+			moreByApple++
+		}
+	}
+	assert.Positive(t, moreByApple)
+}
+
+func TestScrapeSimilarNotFound(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	token, err := GetToken(context.Background(), client, "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ScrapeSimilar(context.Background(), client, token, AppId(1), "us", "en")
+	assert.True(t, errors.Is(err, ErrAppNotFound))
+}
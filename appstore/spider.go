@@ -15,13 +15,17 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
+// Where to start spidering the apps of a genre whose titles start with a letter, in a
+// storefront such as "us" or "gb"
 type GenreLetter struct {
-	Genre    int
-	Letter   string
-	NextPage int
+	Storefront string
+	Genre      int
+	Letter     string
+	NextPage   int
 }
 
 type SpiderProgress struct {
+	Storefront     string
 	Genre          int
 	Letter         string
 	NextPage       null.Int
@@ -48,18 +52,20 @@ func getPageFromUrl(genrePageUrl string) (int64, error) {
 	return page, nil
 }
 
-func Spider(ctx context.Context, client *http.Client, progressChan chan<- SpiderProgress, start []GenreLetter) error {
+func Spider(ctx context.Context, client *Client, progressChan chan<- SpiderProgress, start []GenreLetter) error {
 	errgrp, ctx := errgroup.WithContext(ctx)
+	appUrlPattern := appUrlPattern(client.WebsiteUrl)
 	connectionLimit := make(chan struct{}, 10)
 
 	for _, genreLetter := range start {
+		storefront := genreLetter.Storefront
 		genre := genreLetter.Genre
 		letter := genreLetter.Letter
 		page := genreLetter.NextPage
 
 		errgrp.Go(func() error {
 			// Initial starting page, this will be redirected to an URL with the prettified genre name
-			genrePageUrl := fmt.Sprintf("https://apps.apple.com/us/genre/id%d?letter=%s&page=%d", genre, letter, page)
+			genrePageUrl := fmt.Sprintf("%s/%s/genre/id%d?letter=%s&page=%d", client.WebsiteUrl, storefront, genre, letter, page)
 			for {
 				page, err := getPageFromUrl(genrePageUrl)
 				if err != nil {
@@ -73,7 +79,7 @@ func Spider(ctx context.Context, client *http.Client, progressChan chan<- Spider
 					return ctx.Err()
 				}
 
-				apps, nextPageUrl, err := scrapeGenrePage(ctx, client, genrePageUrl)
+				apps, nextPageUrl, err := scrapeGenrePage(ctx, client, appUrlPattern, genrePageUrl)
 				select {
 				case <-connectionLimit:
 				case <-ctx.Done():
@@ -86,6 +92,7 @@ func Spider(ctx context.Context, client *http.Client, progressChan chan<- Spider
 
 				// Update where we have got to in the database
 				progress := SpiderProgress{
+					Storefront:     storefront,
 					Genre:          genre,
 					Letter:         letter,
 					DiscoveredApps: apps,
@@ -114,20 +121,34 @@ func Spider(ctx context.Context, client *http.Client, progressChan chan<- Spider
 	return errgrp.Wait()
 }
 
-var appUrlPattern *regexp.Regexp = regexp.MustCompile(`^https:\/\/apps.apple.com\/us\/app\/\S+\/id(\d+)$`)
+// Links to apps in any storefront of the website, e.g.
+// https://apps.apple.com/gb/app/bbc-iplayer/id416580485
+func appUrlPattern(websiteUrl string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(websiteUrl) + `\/[a-z]{2}\/app\/\S+\/id(\d+)$`)
+}
 
-func scrapeGenrePage(ctx context.Context, client *http.Client, genrePageUrl string) (apps []AppId, nextPageUrl string, err error) {
+func scrapeGenrePage(ctx context.Context, client *Client, appUrlPattern *regexp.Regexp, genrePageUrl string) (apps []AppId, nextPageUrl string, err error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", genrePageUrl, nil)
 	if err != nil {
 		return
 	}
 
-	resp, err := client.Do(req)
+	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
 		return
 	}
 
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			err = ErrRateLimited
+		} else {
+			err = fmt.Errorf("scrapeGenrePage: %s", resp.Status)
+		}
+		return
+	}
+
 	z := html.NewTokenizer(resp.Body)
 
 	// Look for two types of links
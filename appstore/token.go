@@ -0,0 +1,145 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Returned by the amp-api scrapers when the token has expired or is not valid
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// The expiry time from the exp claim of the JWT
+func (t Token) Expiry() (time.Time, error) {
+	parts := strings.Split(string(t), ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("token payload: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("token does not expire")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// Keeps a token for the amp-api, getting a new one from the website of the storefront
+// before the old one expires. It is safe for concurrent use.
+type TokenManager struct {
+	// How long before the token expires to get a new one
+	RefreshBefore time.Duration
+
+	client  *Client
+	country string
+	now     func() time.Time
+
+	mu      sync.Mutex
+	token   Token
+	expires time.Time
+}
+
+func NewTokenManager(client *Client, country string) *TokenManager {
+	return &TokenManager{
+		RefreshBefore: 10 * time.Minute,
+		client:        client,
+		country:       country,
+		now:           time.Now,
+	}
+}
+
+// Get a token that has not expired, getting a new one if needed. Tokens that are not JWTs,
+// or do not say when they expire, are kept until they are invalidated.
+func (tm *TokenManager) Token(ctx context.Context) (Token, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.token != "" && (tm.expires.IsZero() || tm.now().Add(tm.RefreshBefore).Before(tm.expires)) {
+		return tm.token, nil
+	}
+
+	token, err := GetToken(ctx, tm.client, tm.country)
+	if err != nil {
+		return "", err
+	}
+
+	// The token will be refreshed if Apple does not accept it
+	expires, _ := token.Expiry()
+	tm.token, tm.expires = token, expires
+
+	return token, nil
+}
+
+// Forget the token after Apple does not accept it, so that the next call to Token gets a
+// new one. Other goroutines might have done that already, in which case the new token is
+// kept.
+func (tm *TokenManager) Invalidate(token Token) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.token == token {
+		tm.token, tm.expires = "", time.Time{}
+	}
+}
+
+// Scrape the privacy nutrition labels like ScrapePrivacy does, with a new token if the
+// current one has expired.
+func (tm *TokenManager) ScrapePrivacy(ctx context.Context, appIds []AppId, country string, language string) (map[AppId]PrivacyNutritionLabels, error) {
+	return withToken(ctx, tm, func(token Token) (map[AppId]PrivacyNutritionLabels, error) {
+		return ScrapePrivacy(ctx, tm.client, token, appIds, country, language)
+	})
+}
+
+// Scrape the amp-api catalog details like ScrapeCatalog does, with a new token if the
+// current one has expired.
+func (tm *TokenManager) ScrapeCatalog(ctx context.Context, appIds []AppId, country string, language string) (map[AppId]CatalogDetails, error) {
+	return withToken(ctx, tm, func(token Token) (map[AppId]CatalogDetails, error) {
+		return ScrapeCatalog(ctx, tm.client, token, appIds, country, language)
+	})
+}
+
+// Scrape the related apps like ScrapeSimilar does, with a new token if the current one has
+// expired.
+func (tm *TokenManager) ScrapeSimilar(ctx context.Context, appId AppId, country string, language string) ([]SimilarApp, error) {
+	return withToken(ctx, tm, func(token Token) ([]SimilarApp, error) {
+		return ScrapeSimilar(ctx, tm.client, token, appId, country, language)
+	})
+}
+
+// Call the amp-api with a token, and once more with a new token if Apple does not accept it
+func withToken[T any](ctx context.Context, tm *TokenManager, call func(token Token) (T, error)) (T, error) {
+	token, err := tm.Token(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	result, err := call(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		return result, err
+	}
+
+	tm.Invalidate(token)
+	token, err = tm.Token(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return call(token)
+}
@@ -0,0 +1,152 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeJWT(expires time.Time, generation int) Token {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iss":"fake","gen":%d,"exp":%d}`, generation, expires.Unix())))
+	return Token(header + "." + payload + ".signature")
+}
+
+// A fake App Store website that hands out a new token every time, and an amp-api that only
+// accepts tokens from the second one on
+type tokenServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	issued  int
+	tokens  map[Token]int
+	expires time.Time
+}
+
+func newTokenServer(t *testing.T, expires time.Time) *tokenServer {
+	s := &tokenServer{tokens: make(map[Token]int), expires: expires}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/us/developer/", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.issued++
+		token := fakeJWT(s.expires, s.issued)
+		s.tokens[token] = s.issued
+		s.mu.Unlock()
+
+		config, _ := json.Marshal(map[string]interface{}{"MEDIA_API": map[string]string{"token": string(token)}})
+		fmt.Fprintf(w, `<html><head><meta name="web-experience-app/config/environment" content="%s"></head></html>`,
+			html.EscapeString(url.QueryEscape(string(config))))
+	})
+	mux.HandleFunc("/v1/catalog/", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		generation := s.tokens[Token(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))]
+		s.mu.Unlock()
+
+		if generation < 2 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"1","attributes":{"privacyDetails":{"privacyTypes":[{"identifier":"DATA_NOT_COLLECTED"}]}}}]}`)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// A client that is pointed at the fake servers
+func (s *tokenServer) appStoreClient() *Client {
+	client := NewClient(s.Client())
+	client.WebsiteUrl, client.AmpApiUrl = s.URL, s.URL
+	return client
+}
+
+func TestTokenExpiry(t *testing.T) {
+	expires := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	actual, err := fakeJWT(expires, 1).Expiry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, expires.Equal(actual))
+
+	_, err = Token("fake-token").Expiry()
+	assert.Error(t, err)
+}
+
+func TestTokenManagerRefresh(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+	server := newTokenServer(t, now.Add(time.Hour))
+
+	tm := NewTokenManager(server.appStoreClient(), "us")
+	tm.now = func() time.Time { return now }
+
+	first, err := tm.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token is kept while it has not nearly expired...
+	now = now.Add(45 * time.Minute)
+	token, err := tm.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first, token)
+	assert.Equal(t, 1, server.issued)
+
+	// ...and a new one is got before it does
+	now = now.Add(10 * time.Minute)
+	token, err = tm.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, first, token)
+	assert.Equal(t, 2, server.issued)
+}
+
+func TestTokenManagerUnauthorized(t *testing.T) {
+	server := newTokenServer(t, time.Now().Add(time.Hour))
+	tm := NewTokenManager(server.appStoreClient(), "us")
+
+	// The first token is not accepted, so the labels are scraped again with a new token
+	labels, err := tm.ScrapePrivacy(context.Background(), []AppId{1}, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "DATA_NOT_COLLECTED", labels[1][0].Identifier)
+	assert.Equal(t, 2, server.issued)
+}
+
+func TestTokenManagerConcurrent(t *testing.T) {
+	server := newTokenServer(t, time.Now().Add(time.Hour))
+	tm := NewTokenManager(server.appStoreClient(), "us")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tm.ScrapePrivacy(context.Background(), []AppId{1}, "us", "en"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only one goroutine gets a new token after the first one is not accepted
+	assert.Equal(t, 2, server.issued)
+}
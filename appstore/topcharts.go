@@ -0,0 +1,168 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The top charts feed has at most this many apps
+const maxChartLength = 200
+
+type Chart string
+
+const (
+	ChartTopFree         Chart = "topfreeapplications"
+	ChartTopPaid         Chart = "toppaidapplications"
+	ChartTopGrossing     Chart = "topgrossingapplications"
+	ChartTopFreeIpad     Chart = "topfreeipadapplications"
+	ChartTopPaidIpad     Chart = "toppaidipadapplications"
+	ChartTopGrossingIpad Chart = "topgrossingipadapplications"
+)
+
+// Genre IDs are the same as in Details.GenreIds, e.g. 6014 for games. GenreAll is the
+// chart for all apps.
+const GenreAll int64 = 0
+
+type ChartEntry struct {
+	// Ranks start from 1
+	Rank      int     `json:"rank"`
+	AppId     AppId   `json:"app_id"`
+	BundleId  string  `json:"bundle_id"`
+	Title     string  `json:"title"`
+	Developer string  `json:"developer"`
+	GenreId   int64   `json:"genre_id"`
+	Genre     string  `json:"genre"`
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+}
+
+// Scrape a top chart (top free, top paid or top grossing) for a genre in a storefront,
+// e.g. "us" or "gb". The language is an ISO 639-1 code, e.g. "en", for the titles and
+// genres, which Apple falls back from like in ScrapeDetails. The entries are returned in
+// rank order.
+func ScrapeTopChart(ctx context.Context, client *Client, chart Chart, genreId int64, country string, language string) ([]ChartEntry, error) {
+	chartUrl := fmt.Sprintf("%s/%s/rss/%s/limit=%d", client.LookupUrl, strings.ToLower(country), chart, maxChartLength)
+	if genreId != GenreAll {
+		chartUrl += fmt.Sprintf("/genre=%d", genreId)
+	}
+	chartUrl += "/json"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", chartUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", fakeUserAgent)
+
+	q := req.URL.Query()
+	q.Add("l", strings.ToLower(language))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		} else {
+			return nil, fmt.Errorf("ScrapeTopChart: %s", resp.Status)
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTopChart(body)
+}
+
+// The app IDs of the entries, in rank order, to pass on to ScrapeDetails or ScrapePrivacy
+func ChartAppIds(entries []ChartEntry) []AppId {
+	appIds := make([]AppId, 0, len(entries))
+	for _, entry := range entries {
+		appIds = append(appIds, entry.AppId)
+	}
+	return appIds
+}
+
+func parseTopChart(body []byte) ([]ChartEntry, error) {
+	var response topChartResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	entries := make([]ChartEntry, 0, len(response.Feed.Entry))
+	for i, raw := range response.Feed.Entry {
+		appId, err := strconv.ParseInt(raw.Id.Attributes.Id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("chart entry %d: app ID: %w", i+1, err)
+		}
+
+		var genreId int64
+		if raw.Category.Attributes.Id != "" {
+			genreId, err = strconv.ParseInt(raw.Category.Attributes.Id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("chart entry %d: genre ID: %w", i+1, err)
+			}
+		}
+
+		var price float64
+		if raw.Price.Attributes.Amount != "" {
+			price, err = strconv.ParseFloat(raw.Price.Attributes.Amount, 64)
+			if err != nil {
+				return nil, fmt.Errorf("chart entry %d: price: %w", i+1, err)
+			}
+		}
+
+		entries = append(entries, ChartEntry{
+			Rank:      i + 1,
+			AppId:     AppId(appId),
+			BundleId:  raw.Id.Attributes.BundleId,
+			Title:     raw.Name.Label,
+			Developer: raw.Artist.Label,
+			GenreId:   genreId,
+			Genre:     raw.Category.Attributes.Label,
+			Price:     price,
+			Currency:  raw.Price.Attributes.Currency,
+		})
+	}
+
+	return entries, nil
+}
+
+type rawChartEntry struct {
+	Name   label `json:"im:name"`
+	Artist label `json:"im:artist"`
+	Id     struct {
+		Attributes struct {
+			Id       string `json:"im:id"`
+			BundleId string `json:"im:bundleId"`
+		} `json:"attributes"`
+	} `json:"id"`
+	Category struct {
+		Attributes struct {
+			Id    string `json:"im:id"`
+			Label string `json:"label"`
+		} `json:"attributes"`
+	} `json:"category"`
+	Price struct {
+		Attributes struct {
+			Amount   string `json:"amount"`
+			Currency string `json:"currency"`
+		} `json:"attributes"`
+	} `json:"im:price"`
+}
+
+type topChartResponse struct {
+	Feed struct {
+		Entry oneOrMany[rawChartEntry] `json:"entry"`
+	} `json:"feed"`
+}
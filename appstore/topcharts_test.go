@@ -0,0 +1,65 @@
+package appstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestTopChartFree(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	entries, err := ScrapeTopChart(context.Background(), client, ChartTopFree, GenreAll, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, entries)
+	assert.LessOrEqual(t, len(entries), maxChartLength)
+
+	for i, entry := range entries {
+		assert.Equal(t, i+1, entry.Rank)
+		assert.Positive(t, entry.AppId)
+		assert.Zero(t, entry.Price)
+	}
+}
+
+func TestTopChartPaidGenre(t *testing.T) {
+	client := NewClient(httprecord.Client(t))
+
+	// Games
+	entries, err := ScrapeTopChart(context.Background(), client, ChartTopPaid, 6014, "gb", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Positive(t, entry.Price)
+		assert.Equal(t, "GBP", entry.Currency)
+	}
+}
+
+func TestParseTopChartSingleEntry(t *testing.T) {
+	body := []byte(`{"feed":{"entry":{"im:name":{"label":"Clock"},"im:price":{"label":"Get","attributes":{"amount":"0.00000","currency":"USD"}},"id":{"label":"https://apps.apple.com/us/app/clock/id1584215688?uo=2","attributes":{"im:id":"1584215688","im:bundleId":"com.apple.mobiletimer"}},"im:artist":{"label":"Apple"},"category":{"attributes":{"im:id":"6002","term":"Utilities","label":"Utilities"}}}}}`)
+
+	entries, err := parseTopChart(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []ChartEntry{{
+		Rank:      1,
+		AppId:     1584215688,
+		BundleId:  "com.apple.mobiletimer",
+		Title:     "Clock",
+		Developer: "Apple",
+		GenreId:   6002,
+		Genre:     "Utilities",
+		Currency:  "USD",
+	}}, entries)
+	assert.Equal(t, []AppId{1584215688}, ChartAppIds(entries))
+}
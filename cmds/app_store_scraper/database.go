@@ -7,31 +7,55 @@ import (
 	"encoding/json"
 
 	"github.com/andybalholm/brotli"
+	"gopkg.in/guregu/null.v4"
 
 	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
 )
 
-func dbSpiderProgress(ctx context.Context, db *sql.DB) ([]appstore.GenreLetter, error) {
+// Where to carry on spidering the storefronts from. Storefronts that have not been
+// spidered before start from the first page of every genre and letter.
+func dbSpiderProgress(ctx context.Context, db *sql.DB, storefronts []string) ([]appstore.GenreLetter, error) {
 	var progress []appstore.GenreLetter
 
-	rows, err := db.QueryContext(ctx, "SELECT genre, letter, page_reached FROM spider_progress WHERE page_reached IS NOT NULL")
+	const seedQuery = `
+	INSERT INTO spider_progress (storefront, genre, letter, page_reached)
+	SELECT DISTINCT ?, genre, letter, 1 FROM spider_progress WHERE true
+	ON CONFLICT DO NOTHING`
+
+	for _, storefront := range storefronts {
+		if _, err := db.ExecContext(ctx, seedQuery, storefront); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT storefront, genre, letter, page_reached FROM spider_progress WHERE page_reached IS NOT NULL")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	wanted := make(map[string]bool)
+	for _, storefront := range storefronts {
+		wanted[storefront] = true
+	}
+
 	for rows.Next() {
 		var (
-			genre    int
-			letter   string
-			nextPage int
+			storefront string
+			genre      int
+			letter     string
+			nextPage   int
 		)
 
-		if err := rows.Scan(&genre, &letter, &nextPage); err != nil {
+		if err := rows.Scan(&storefront, &genre, &letter, &nextPage); err != nil {
 			return nil, err
 		}
 
-		progress = append(progress, appstore.GenreLetter{Genre: genre, Letter: letter, NextPage: nextPage})
+		if !wanted[storefront] {
+			continue
+		}
+
+		progress = append(progress, appstore.GenreLetter{Storefront: storefront, Genre: genre, Letter: letter, NextPage: nextPage})
 	}
 
 	if err := rows.Err(); err != nil {
@@ -41,7 +65,7 @@ func dbSpiderProgress(ctx context.Context, db *sql.DB) ([]appstore.GenreLetter,
 	return progress, nil
 }
 
-func Writer(ctx context.Context, db *sql.DB, spiderProgressChan <-chan appstore.SpiderProgress, scrapedAppsChan <-chan []ScrapedApp, notFoundAppChan <-chan []appstore.AppId) error {
+func Writer(ctx context.Context, db *sql.DB, spiderProgressChan <-chan appstore.SpiderProgress, scrapedAppsChan <-chan []ScrapedApp, notFoundAppChan <-chan NotFoundApps) error {
 	writer, err := newWriter(ctx, db)
 	if err != nil {
 		return err
@@ -76,7 +100,7 @@ func Writer(ctx context.Context, db *sql.DB, spiderProgressChan <-chan appstore.
 				return nil
 			}
 
-			if err := writer.InsertNotFoundApps(ctx, notFoundApps); err != nil {
+			if err := writer.InsertNotFoundApps(ctx, notFoundApps.Country, notFoundApps.AppIds); err != nil {
 				return err
 			}
 		}
@@ -88,6 +112,7 @@ type writer struct {
 	insertApp            *sql.Stmt
 	insertScraped        *sql.Stmt
 	insertNotFound       *sql.Stmt
+	insertPrice          *sql.Stmt
 	updateSpiderProgress *sql.Stmt
 }
 
@@ -97,17 +122,31 @@ func newWriter(ctx context.Context, db *sql.DB) (*writer, error) {
 		return nil, err
 	}
 
-	insertScraped, err := db.PrepareContext(ctx, "INSERT INTO scraped_apps (app_id, data) VALUES (?, ?)")
+	insertScraped, err := db.PrepareContext(ctx, "INSERT INTO scraped_apps (app_id, country, data, similar_error) VALUES (?, ?, ?, ?)")
 	if err != nil {
 		return nil, err
 	}
 
-	insertNotFound, err := db.PrepareContext(ctx, "INSERT INTO not_found_apps (app_id) VALUES (?)")
+	insertNotFound, err := db.PrepareContext(ctx, "INSERT INTO not_found_apps (app_id, country) VALUES (?, ?)")
 	if err != nil {
 		return nil, err
 	}
 
-	updateSpiderProgress, err := db.PrepareContext(ctx, "UPDATE spider_progress SET page_reached = ? WHERE genre = ? AND letter = ?")
+	// An app can be scraped in a storefront after its price there was looked up from
+	// another storefront, so the newer price replaces the older one
+	insertPrice, err := db.PrepareContext(ctx, `
+	INSERT INTO prices (app_id, country, currency, price, original_price)
+	VALUES (:app_id, :country, :currency, :price, :original_price)
+	ON CONFLICT (app_id, country) DO UPDATE SET
+		scraped_when = excluded.scraped_when,
+		currency = excluded.currency,
+		price = excluded.price,
+		original_price = excluded.original_price`)
+	if err != nil {
+		return nil, err
+	}
+
+	updateSpiderProgress, err := db.PrepareContext(ctx, "UPDATE spider_progress SET page_reached = ? WHERE storefront = ? AND genre = ? AND letter = ?")
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +156,7 @@ func newWriter(ctx context.Context, db *sql.DB) (*writer, error) {
 		insertApp:            insertApp,
 		insertScraped:        insertScraped,
 		insertNotFound:       insertNotFound,
+		insertPrice:          insertPrice,
 		updateSpiderProgress: updateSpiderProgress,
 	}
 
@@ -128,6 +168,7 @@ func (w *writer) Close() error {
 		w.insertApp.Close(),
 		w.insertScraped.Close(),
 		w.insertNotFound.Close(),
+		w.insertPrice.Close(),
 		w.updateSpiderProgress.Close(),
 	}
 
@@ -147,7 +188,7 @@ func (w *writer) UpdateSpiderProgress(ctx context.Context, progress appstore.Spi
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.StmtContext(ctx, w.updateSpiderProgress).ExecContext(ctx, progress.NextPage, progress.Genre, progress.Letter); err != nil {
+	if _, err := tx.StmtContext(ctx, w.updateSpiderProgress).ExecContext(ctx, progress.NextPage, progress.Storefront, progress.Genre, progress.Letter); err != nil {
 		return err
 	}
 
@@ -167,6 +208,10 @@ func (w *writer) InsertScrapedApps(ctx context.Context, scrapedApps []ScrapedApp
 	}
 	defer tx.Rollback()
 
+	insertApp := tx.StmtContext(ctx, w.insertApp)
+	insertScraped := tx.StmtContext(ctx, w.insertScraped)
+	insertPrice := tx.StmtContext(ctx, w.insertPrice)
+
 	for _, scrapedApp := range scrapedApps {
 		uncompressed, err := json.Marshal(scrapedApp)
 		if err != nil {
@@ -178,19 +223,51 @@ func (w *writer) InsertScrapedApps(ctx context.Context, scrapedApps []ScrapedApp
 			return err
 		}
 
-		if _, err := tx.StmtContext(ctx, w.insertApp).ExecContext(ctx, scrapedApp.AppId); err != nil {
+		if _, err := insertApp.ExecContext(ctx, scrapedApp.AppId); err != nil {
 			return err
 		}
 
-		if _, err := tx.StmtContext(ctx, w.insertScraped).ExecContext(ctx, scrapedApp.AppId, compressed.Bytes()); err != nil {
+		// Try and discover more apps from the similar apps
+		for _, similarApp := range scrapedApp.SimilarApps {
+			if _, err := insertApp.ExecContext(ctx, similarApp.AppId); err != nil {
+				return err
+			}
+		}
+
+		var similarErr null.String
+		if scrapedApp.similarErr != nil {
+			similarErr = null.StringFrom(scrapedApp.similarErr.Error())
+		}
+
+		if _, err := insertScraped.ExecContext(ctx, scrapedApp.AppId, scrapedApp.Country, compressed.Bytes(), similarErr); err != nil {
 			return err
 		}
+
+		for _, priceInfo := range scrapedApp.prices {
+			// If an app is not available in a storefront, there is no price to insert
+			if !priceInfo.Available {
+				continue
+			}
+
+			args := []interface{}{
+				sql.Named("app_id", scrapedApp.AppId),
+				sql.Named("country", priceInfo.Country),
+				sql.Named("currency", priceInfo.Currency),
+				sql.Named("price", priceInfo.Price),
+				sql.Named("original_price", priceInfo.OriginalPrice),
+			}
+
+			if _, err := insertPrice.ExecContext(ctx, args...); err != nil {
+				return err
+			}
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (w *writer) InsertNotFoundApps(ctx context.Context, notFoundApps []appstore.AppId) error {
+// Record the apps that were not found in the storefront
+func (w *writer) InsertNotFoundApps(ctx context.Context, country string, notFoundApps []appstore.AppId) error {
 	tx, err := w.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -202,7 +279,7 @@ func (w *writer) InsertNotFoundApps(ctx context.Context, notFoundApps []appstore
 			return err
 		}
 
-		if _, err := tx.StmtContext(ctx, w.insertNotFound).ExecContext(ctx, appId); err != nil {
+		if _, err := tx.StmtContext(ctx, w.insertNotFound).ExecContext(ctx, appId, country); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/time/rate"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+// Look up every app of the developers of the apps that have been scraped, and add them to
+// the apps to scrape. Run scrape again afterwards to scrape the newly discovered apps.
+func developers(ctx context.Context, db *sql.DB) error {
+	developerIds, err := dbScrapedDevelopers(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	client := makeClient()
+	defer client.HTTPClient.CloseIdleConnections()
+	rateLimiter := rate.NewLimiter(rate.Every(RateLimit), 1)
+
+	progress := makeProgressBar(len(developerIds), "developers")
+
+	var found, added int64
+	for _, chunk := range chunks(developerIds, appstore.MaxDeveloperIds) {
+		chunk := chunk
+
+		// One call for each chunk, so that the rate limiter sees most requests. A chunk whose
+		// lookup reaches the limit on the number of results takes a few more.
+		developers, err := withRateLimit(ctx, progress, rateLimiter, func() (map[int64][]appstore.Details, error) {
+			return appstore.ScrapeDeveloperApps(ctx, client, chunk, country, language)
+		})
+		if err != nil {
+			return err
+		}
+
+		var apps []appstore.Details
+		for _, developerApps := range developers {
+			apps = append(apps, developerApps...)
+		}
+
+		n, err := insertDiscoveredApps(ctx, db, apps)
+		if err != nil {
+			return err
+		}
+
+		found += int64(len(apps))
+		added += n
+		progress.Add(len(chunk))
+	}
+
+	log.Printf("Found %d apps from %d developers, of which %d are new.", found, len(developerIds), added)
+	return nil
+}
+
+// The distinct developers of the scraped apps
+func dbScrapedDevelopers(ctx context.Context, db *sql.DB) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT data FROM scraped_apps")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int64]bool)
+	var developerIds []int64
+
+	for rows.Next() {
+		var compressed []byte
+		if err := rows.Scan(&compressed); err != nil {
+			return nil, err
+		}
+
+		var scrapedApp struct {
+			DeveloperId int64 `json:"developer_id"`
+		}
+		if err := json.NewDecoder(brotli.NewReader(bytes.NewReader(compressed))).Decode(&scrapedApp); err != nil {
+			return nil, err
+		}
+
+		if scrapedApp.DeveloperId != 0 && !seen[scrapedApp.DeveloperId] {
+			seen[scrapedApp.DeveloperId] = true
+			developerIds = append(developerIds, scrapedApp.DeveloperId)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return developerIds, nil
+}
@@ -8,13 +8,24 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
 )
 
-func Import(ctx context.Context, db *sql.DB, inputFilePaths []string) error {
+// Import app IDs, or bundle IDs if bundleIds is true, from files with one ID on each line
+func Import(ctx context.Context, db *sql.DB, inputFilePaths []string, bundleIds bool) error {
 	var total int64
 
 	for _, inputFile := range inputFilePaths {
-		n, err := importAppIds(ctx, db, inputFile)
+		var n int64
+		var err error
+		if bundleIds {
+			n, err = importBundleIds(ctx, db, inputFile)
+		} else {
+			n, err = importAppIds(ctx, db, inputFile)
+		}
 		if err != nil {
 			return err
 		}
@@ -73,3 +84,66 @@ func importAppIds(ctx context.Context, db *sql.DB, inputFilePath string) (int64,
 
 	return n, nil
 }
+
+// Resolve bundle IDs to app IDs in the storefront, and import the apps that exist
+func importBundleIds(ctx context.Context, db *sql.DB, inputFilePath string) (int64, error) {
+	file, err := os.Open(inputFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// Bundle IDs are matched without regard to case, so only look up each one once
+	var bundleIds []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		bundleId := strings.TrimSpace(scanner.Text())
+		if bundleId == "" || seen[strings.ToLower(bundleId)] {
+			continue
+		}
+		seen[strings.ToLower(bundleId)] = true
+		bundleIds = append(bundleIds, bundleId)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	client := makeClient()
+	defer client.HTTPClient.CloseIdleConnections()
+	rateLimiter := rate.NewLimiter(rate.Every(RateLimit), 1)
+
+	progress := makeProgressBar(len(bundleIds), "bundle IDs")
+
+	// Each chunk is one lookup, and is imported as soon as it has been resolved
+	var imported int64
+	for _, chunk := range chunks(bundleIds, appstore.MaxLookupIds) {
+		chunk := chunk
+
+		apps, err := withRateLimit(ctx, progress, rateLimiter, func() (map[string]appstore.Details, error) {
+			return appstore.ScrapeDetailsByBundleId(ctx, client, chunk, country, language)
+		})
+		if err != nil {
+			return imported, err
+		}
+
+		details := make([]appstore.Details, 0, len(apps))
+		for _, app := range apps {
+			details = append(details, app)
+		}
+
+		if _, err := insertDiscoveredApps(ctx, db, details); err != nil {
+			return imported, err
+		}
+
+		imported += int64(len(details))
+		progress.Add(len(chunk))
+	}
+
+	if imported < int64(len(bundleIds)) {
+		log.Printf("%d of %d bundle IDs in %s were not found.", int64(len(bundleIds))-imported, len(bundleIds), inputFilePath)
+	}
+
+	return imported, nil
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -26,14 +27,39 @@ import (
 )
 
 const (
-	DatabaseVersion      uint8 = 2
-	country                    = "us"
-	language                   = "en"
-	NumWorkers                 = 4
-	ChunkSize                  = 100
-	QueueSize                  = 10_000
-	RateLimit                  = 1 * time.Second
-	RateLimitedSleepTime       = 60 * time.Second
+	DatabaseVersion    uint8 = 5
+	NumWorkers               = 4
+	SimilarConcurrency       = 4
+	ChunkSize                = 100
+	QueueSize                = 10_000
+	RateLimit                = 1 * time.Second
+	SimilarRateLimit         = 250 * time.Millisecond
+)
+
+var (
+	// How long to stop making requests after Apple rate limits us. A variable so that the
+	// tests do not have to wait as long.
+	RateLimitedSleepTime = 60 * time.Second
+
+	// The storefront and language to scrape
+	country  = "us"
+	language = "en"
+
+	// The other storefronts to get the prices of paid apps in. None by default, as each one
+	// is another rate limited lookup for every chunk with a paid app.
+	additionalCountriesForPrice []string
+
+	// Whether to scrape the related apps of each app, to discover more apps
+	scrapeSimilar = false
+
+	// The storefronts to spider
+	storefronts = []string{"us"}
+
+	// The addresses of the App Store, so that the scraper can be pointed at fake servers
+	// for testing
+	websiteUrl = appstore.DefaultWebsiteUrl
+	lookupUrl  = appstore.DefaultLookupUrl
+	ampApiUrl  = appstore.DefaultAmpApiUrl
 )
 
 //go:embed schema.sql
@@ -75,6 +101,8 @@ func main() {
 		Use:   "app_store_scraper",
 		Short: "Scrape the Apple App Store",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			lowerStorefronts()
+
 			var created bool
 			var err error
 			db, created, err = database.OpenOrCreate(databasePath, database.DatabaseAppStore, DatabaseVersion)
@@ -104,16 +132,25 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&databasePath, "database", "", "Path to database")
 	rootCmd.MarkPersistentFlagRequired("database")
 
+	// So that the scraper can be pointed at fake servers for testing
+	rootCmd.PersistentFlags().StringVar(&websiteUrl, "website-url", websiteUrl, "Address of the App Store website")
+	rootCmd.PersistentFlags().StringVar(&lookupUrl, "lookup-url", lookupUrl, "Address of the iTunes lookup API")
+	rootCmd.PersistentFlags().StringVar(&ampApiUrl, "amp-api-url", ampApiUrl, "Address of the App Store's amp-api")
+
+	var importBundleIds bool
 	importCmd := &cobra.Command{
 		Use: "import",
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := Import(ctx, db, args); err != nil && !errors.Is(err, context.Canceled) {
+			if err := Import(ctx, db, args, importBundleIds); err != nil && !errors.Is(err, context.Canceled) {
 				log.Printf("%+v", err)
 			}
 		},
 		Args: cobra.MinimumNArgs(1),
 	}
 	importCmd.MarkFlagRequired("input")
+	importCmd.Flags().BoolVar(&importBundleIds, "bundle-ids", false, "The files have bundle IDs rather than app IDs, which are looked up in the storefront")
+	importCmd.Flags().StringVar(&country, "country", country, "Storefront to look up the bundle IDs in")
+	importCmd.Flags().StringVar(&language, "language", language, "Language to look up the bundle IDs in")
 	rootCmd.AddCommand(importCmd)
 
 	spiderCmd := &cobra.Command{
@@ -125,8 +162,37 @@ func main() {
 			}
 		},
 	}
+	spiderCmd.Flags().StringSliceVar(&storefronts, "storefronts", storefronts, "Storefronts to crawl, e.g. us,gb,de")
 	rootCmd.AddCommand(spiderCmd)
 
+	searchCmd := &cobra.Command{
+		Use:   "search TERM...",
+		Short: "Search the App Store for apps matching the terms",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := search(ctx, db, args); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("%+v", err)
+			}
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+	searchCmd.Flags().StringVar(&country, "country", country, "Storefront to search")
+	searchCmd.Flags().StringVar(&language, "language", language, "Language to search in")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", searchLimit, "Maximum number of results for each term")
+	rootCmd.AddCommand(searchCmd)
+
+	developersCmd := &cobra.Command{
+		Use:   "developers",
+		Short: "Find the other apps of the developers of the scraped apps",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := developers(ctx, db); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("%+v", err)
+			}
+		},
+	}
+	developersCmd.Flags().StringVar(&country, "country", country, "Storefront to look up the developers in")
+	developersCmd.Flags().StringVar(&language, "language", language, "Language to look up the developers in")
+	rootCmd.AddCommand(developersCmd)
+
 	scrapeCmd := &cobra.Command{
 		Use: "scrape",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -135,11 +201,27 @@ func main() {
 			}
 		},
 	}
+	scrapeCmd.Flags().StringVar(&country, "country", country, "Storefront to scrape")
+	scrapeCmd.Flags().StringVar(&language, "language", language, "Language to scrape")
+	scrapeCmd.Flags().StringSliceVar(&additionalCountriesForPrice, "price-countries", additionalCountriesForPrice, "Other storefronts to scrape the prices of paid apps in, e.g. gb,de. Each one is another rate limited lookup for every chunk with a paid app. Apps that are free in --country are not priced in them, and whether an app is on sale (its original price) is not known")
+	scrapeCmd.Flags().BoolVar(&scrapeSimilar, "similar", scrapeSimilar, "Also scrape the related apps of each app and add them to the apps to scrape")
 	rootCmd.AddCommand(scrapeCmd)
 
 	rootCmd.Execute()
 }
 
+// The storefronts are stored in lower case, e.g. gb rather than GB, but they can be given
+// in either case on the command line
+func lowerStorefronts() {
+	country = strings.ToLower(country)
+	for i := range storefronts {
+		storefronts[i] = strings.ToLower(storefronts[i])
+	}
+	for i := range additionalCountriesForPrice {
+		additionalCountriesForPrice[i] = strings.ToLower(additionalCountriesForPrice[i])
+	}
+}
+
 // Create an HTTP client with settings for multiple connections to the same hosts
 // http://tleyden.github.io/blog/2016/11/21/tuning-the-go-http-client-library-for-load-testing/
 func makeHTTPClient() *http.Client {
@@ -153,6 +235,13 @@ func makeHTTPClient() *http.Client {
 	return retryableClient.StandardClient()
 }
 
+// Create an App Store client that uses the addresses from the command line
+func makeClient() *appstore.Client {
+	client := appstore.NewClient(makeHTTPClient())
+	client.WebsiteUrl, client.LookupUrl, client.AmpApiUrl = websiteUrl, lookupUrl, ampApiUrl
+	return client
+}
+
 func retryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
 	// Do not retry on 429
 	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
@@ -184,7 +273,7 @@ func makeProgressBar(max int, iterationString string) *progressbar.ProgressBar {
 }
 
 func spider(ctx context.Context, db *sql.DB) error {
-	spiderStart, err := dbSpiderProgress(ctx, db)
+	spiderStart, err := dbSpiderProgress(ctx, db, storefronts)
 	if err != nil {
 		return err
 	}
@@ -227,8 +316,8 @@ func spider(ctx context.Context, db *sql.DB) error {
 	})
 
 	errgrp.Go(func() error {
-		client := makeHTTPClient()
-		defer client.CloseIdleConnections()
+		client := makeClient()
+		defer client.HTTPClient.CloseIdleConnections()
 		return appstore.Spider(ctx, client, spiderProgressIn, spiderStart)
 	})
 
@@ -236,7 +325,7 @@ func spider(ctx context.Context, db *sql.DB) error {
 }
 
 func scrape(ctx context.Context, db *sql.DB) error {
-	total, remaining, err := dbStatistics(ctx, db)
+	total, remaining, err := dbStatistics(ctx, db, country)
 	if err != nil {
 		return err
 	}
@@ -244,20 +333,31 @@ func scrape(ctx context.Context, db *sql.DB) error {
 	progress := makeProgressBar(int(total), "apps")
 	progress.Set64(total - remaining)
 
-	client := makeHTTPClient()
-	defer client.CloseIdleConnections()
+	client := makeClient()
+	defer client.HTTPClient.CloseIdleConnections()
 	rateLimiter := rate.NewLimiter(rate.Every(RateLimit), 1)
-
-	// Get the JWT token so we can authenticate against the API
-	token, err := appstore.GetToken(ctx, client)
-	if err != nil {
+	// There is one related apps request for each app, rather than for each chunk, so they
+	// have their own, faster limit
+	similarRateLimiter := rate.NewLimiter(rate.Every(SimilarRateLimit), 1)
+
+	// Get the JWT token so we can authenticate against the API. It is refreshed before it
+	// expires, as a scrape can take days.
+	tokens := appstore.NewTokenManager(client, country)
+	if _, err := tokens.Token(ctx); err != nil {
 		return err
 	}
 
+	// The related apps that could not be scraped last time may lead to more apps to scrape
+	if scrapeSimilar {
+		if err := retrySimilarApps(ctx, db, client, progress, similarRateLimiter, tokens); err != nil {
+			return err
+		}
+	}
+
 	for {
 		// Get apps to scrape
 		progress.Describe("Getting apps to scrape")
-		appIds, err := appsToScrape(ctx, db, QueueSize)
+		appIds, err := appsToScrape(ctx, db, country, QueueSize)
 		if err != nil {
 			return err
 		}
@@ -271,8 +371,8 @@ func scrape(ctx context.Context, db *sql.DB) error {
 		scrapedAppsIn := make(chan []ScrapedApp)
 		scrapedAppsOut := make(chan []ScrapedApp)
 
-		notFoundAppsIn := make(chan []appstore.AppId)
-		notFoundAppsOut := make(chan []appstore.AppId)
+		notFoundAppsIn := make(chan NotFoundApps)
+		notFoundAppsOut := make(chan NotFoundApps)
 
 		toScrape := make(chan []appstore.AppId, NumWorkers)
 
@@ -330,7 +430,7 @@ func scrape(ctx context.Context, db *sql.DB) error {
 						return ctx.Err()
 
 					case notFoundAppsOut <- notFoundApps:
-						progress.Add(len(notFoundApps))
+						progress.Add(len(notFoundApps.AppIds))
 					}
 				}
 			}
@@ -356,7 +456,7 @@ func scrape(ctx context.Context, db *sql.DB) error {
 								return nil
 							}
 
-							if err := Scrape(ctx, client, progress, rateLimiter, token, scrapedAppsIn, notFoundAppsIn, appIds); err != nil {
+							if err := Scrape(ctx, client, progress, rateLimiter, similarRateLimiter, tokens, scrapedAppsIn, notFoundAppsIn, appIds); err != nil {
 								// Is this a fatal error or shall we ignore it?
 
 								if errors.Is(err, context.Canceled) {
@@ -393,7 +493,8 @@ func scrape(ctx context.Context, db *sql.DB) error {
 	}
 }
 
-func dbStatistics(ctx context.Context, db *sql.DB) (total int64, remaining int64, err error) {
+// The number of apps, and the number that are still to be scraped in the storefront
+func dbStatistics(ctx context.Context, db *sql.DB, country string) (total int64, remaining int64, err error) {
 	if err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM apps`).Scan(&total); err != nil {
 		return
 	}
@@ -406,29 +507,30 @@ func dbStatistics(ctx context.Context, db *sql.DB) (total int64, remaining int64
 		FROM
 			apps
 		WHERE
-			(app_id NOT IN (SELECT app_id FROM scraped_apps)) AND (app_id NOT IN (SELECT app_id FROM not_found_apps))
+			(app_id NOT IN (SELECT app_id FROM scraped_apps WHERE country = :country)) AND (app_id NOT IN (SELECT app_id FROM not_found_apps WHERE country = :country))
 	)`
 
-	if err = db.QueryRowContext(ctx, query).Scan(&remaining); err != nil {
+	if err = db.QueryRowContext(ctx, query, sql.Named("country", country)).Scan(&remaining); err != nil {
 		return
 	}
 
 	return
 }
 
-func appsToScrape(ctx context.Context, db *sql.DB, n int) ([]appstore.AppId, error) {
+// Up to n apps that have not been scraped in the storefront yet
+func appsToScrape(ctx context.Context, db *sql.DB, country string, n int) ([]appstore.AppId, error) {
 	const query = `
 	SELECT
 		app_id
 	FROM
 		apps
 	WHERE
-		(app_id NOT IN (SELECT app_id FROM scraped_apps)) AND (app_id NOT IN (SELECT app_id FROM not_found_apps))
-	LIMIT ?`
+		(app_id NOT IN (SELECT app_id FROM scraped_apps WHERE country = :country)) AND (app_id NOT IN (SELECT app_id FROM not_found_apps WHERE country = :country))
+	LIMIT :limit`
 
 	var appIds []appstore.AppId
 
-	rows, err := db.QueryContext(ctx, query, n)
+	rows, err := db.QueryContext(ctx, query, sql.Named("country", country), sql.Named("limit", n))
 	if err != nil {
 		return nil, err
 	}
@@ -449,12 +551,12 @@ func appsToScrape(ctx context.Context, db *sql.DB, n int) ([]appstore.AppId, err
 	return appIds, nil
 }
 
-func chunks(xs []appstore.AppId, chunkSize int) [][]appstore.AppId {
+func chunks[T any](xs []T, chunkSize int) [][]T {
 	if len(xs) == 0 {
 		return nil
 	}
 	numChunks := (len(xs) + chunkSize - 1) / chunkSize
-	divided := make([][]appstore.AppId, 0, numChunks)
+	divided := make([][]T, 0, numChunks)
 
 	for i := 0; i < len(xs); i += chunkSize {
 		end := i + chunkSize
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+	"gopkg.in/guregu/null.v4"
+
+	"cmds/internal/database"
+	"cmds/internal/dbtest"
+	"cmds/internal/fakestore"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+// Decompress and decode a scraped app from the database
+func scrapedApp(t *testing.T, db *sql.DB, appId appstore.AppId) ScrapedApp {
+	var compressed []byte
+	if err := db.QueryRow("SELECT data FROM scraped_apps WHERE app_id = ? AND country = ?", appId, country).Scan(&compressed); err != nil {
+		t.Fatal(err)
+	}
+
+	var app ScrapedApp
+	if err := json.NewDecoder(brotli.NewReader(bytes.NewReader(compressed))).Decode(&app); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+// Start a fake App Store and point the scraper at it
+func startFakeAppStore(t *testing.T) *fakestore.AppStore {
+	store := fakestore.NewAppStore()
+	t.Cleanup(store.Close)
+
+	defaultWebsiteUrl, defaultLookupUrl, defaultAmpApiUrl := websiteUrl, lookupUrl, ampApiUrl
+	websiteUrl, lookupUrl, ampApiUrl = store.URL, store.URL, store.URL
+	t.Cleanup(func() {
+		websiteUrl, lookupUrl, ampApiUrl = defaultWebsiteUrl, defaultLookupUrl, defaultAmpApiUrl
+	})
+
+	return store
+}
+
+func fakeApp(appId appstore.AppId, title string, genreId int64) fakestore.AppStoreApp {
+	return fakestore.AppStoreApp{
+		Details: appstore.Details{
+			AppId:          appId,
+			BundleId:       "com.example.app" + title,
+			Title:          title,
+			GenreIds:       []int64{genreId},
+			PrimaryGenreId: genreId,
+			Currency:       "USD",
+		},
+		Privacy: appstore.PrivacyNutritionLabels{
+			{Identifier: "DATA_NOT_COLLECTED"},
+		},
+	}
+}
+
+func TestScrape(t *testing.T) {
+	store := startFakeAppStore(t)
+	calculator := fakeApp(1, "Calculator", 6007)
+	calculator.VersionHistory = []appstore.Version{
+		{Version: "1.1", ReleaseNotes: "Bug fixes", Released: null.TimeFrom(time.Date(2022, time.May, 1, 17, 0, 0, 0, time.UTC))},
+		{Version: "1.0", Released: null.TimeFrom(time.Date(2021, time.September, 20, 17, 0, 0, 0, time.UTC))},
+	}
+	calculator.Histogram = appstore.Histogram{Stars1: 1, Stars2: 2, Stars3: 3, Stars4: 4, Stars5: 5}
+	calculator.InAppPurchases = []appstore.InAppPurchase{
+		{Id: "com.example.calculator.pro", Name: "Pro", Price: 2.99, FormattedPrice: "$2.99", Currency: "USD"},
+		{Id: "com.example.calculator.plus", Name: "Plus", Price: 0.99, FormattedPrice: "$0.99", Currency: "USD", Subscription: true, SubscriptionPeriod: "P1M"},
+	}
+	store.AddApp(calculator)
+	store.AddApp(fakeApp(2, "Clock", 6007))
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []int{1, 2, 3} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM not_found_apps WHERE app_id = 3"))
+
+	scraped := scrapedApp(t, db, 1)
+	assert.Equal(t, "Calculator", scraped.Title)
+	assert.Equal(t, calculator.Privacy, scraped.PrivacyNutritionLabels)
+	assert.Equal(t, calculator.Histogram, scraped.Histogram)
+	assert.Equal(t, calculator.InAppPurchases, scraped.InAppPurchases)
+	if assert.Len(t, scraped.VersionHistory, 2) {
+		assert.Equal(t, "1.1", scraped.VersionHistory[0].Version)
+		assert.True(t, calculator.VersionHistory[0].Released.Time.Equal(scraped.VersionHistory[0].Released.Time))
+	}
+}
+
+func TestScrapeCountries(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+	unavailable := fakeApp(2, "Clock", 6007)
+	unavailable.Storefronts = []string{"us"}
+	store.AddApp(unavailable)
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []int{1, 2} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(c string) { country = c }(country)
+	for _, c := range []string{"us", "gb"} {
+		country = c
+		if err := scrape(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The apps that were scraped in the first storefront are scraped again in the second one
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 1 AND country = 'gb'"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 2 AND country = 'us'"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM not_found_apps WHERE app_id = 2 AND country = 'gb'"))
+}
+
+func TestLowerStorefronts(t *testing.T) {
+	defer func(c string, s []string, p []string) {
+		country, storefronts, additionalCountriesForPrice = c, s, p
+	}(country, storefronts, additionalCountriesForPrice)
+
+	country, storefronts, additionalCountriesForPrice = "GB", []string{"US", "de"}, []string{"Fr"}
+	lowerStorefronts()
+
+	assert.Equal(t, "gb", country)
+	assert.Equal(t, []string{"us", "de"}, storefronts)
+	assert.Equal(t, []string{"fr"}, additionalCountriesForPrice)
+}
+
+func TestScrapePrices(t *testing.T) {
+	store := startFakeAppStore(t)
+	paid := fakeApp(1, "Calculator Pro", 6007)
+	paid.Details.Price = 1.99
+	paid.Storefronts = []string{"us", "gb"}
+	paid.Prices = map[string]fakestore.Price{"gb": {Price: 1.49, Currency: "GBP"}}
+	store.AddApp(paid)
+	store.AddApp(fakeApp(2, "Calculator", 6007))
+
+	defer func(countries []string) { additionalCountriesForPrice = countries }(additionalCountriesForPrice)
+	additionalCountriesForPrice = []string{"gb", "de"}
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []int{1, 2} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The paid app is not available in Germany, and free apps have no prices
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 1 AND country = 'us' AND currency = 'USD' AND price = 1.99"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 1 AND country = 'gb' AND currency = 'GBP' AND price = 1.49"))
+}
+
+func TestScrapePricesStorefrontError(t *testing.T) {
+	store := startFakeAppStore(t)
+	paid := fakeApp(1, "Calculator Pro", 6007)
+	paid.Details.Price = 1.99
+	paid.Prices = map[string]fakestore.Price{"gb": {Price: 1.49, Currency: "GBP"}, "de": {Price: 1.79, Currency: "EUR"}}
+	store.AddApp(paid)
+	store.FailQuery("/lookup", "country", "gb", fakestore.FaultMalformed, -1)
+
+	defer func(countries []string) { additionalCountriesForPrice = countries }(additionalCountriesForPrice)
+	additionalCountriesForPrice = []string{"gb", "de"}
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The app and its prices in the other storefronts are kept
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 1"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 1 AND country = 'de' AND price = 1.79"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 1 AND country = 'gb'"))
+}
+
+func TestScrapeSimilar(t *testing.T) {
+	store := startFakeAppStore(t)
+	calculator := fakeApp(1, "Calculator", 6007)
+	calculator.Similar = []appstore.SimilarApp{
+		{AppId: 2, Title: "Clock", View: appstore.ViewCustomersAlsoBought},
+		{AppId: 3, Title: "Compass", View: appstore.ViewMoreByDeveloper},
+	}
+	store.AddApp(calculator)
+	store.AddApp(fakeApp(2, "Clock", 6007))
+
+	defer func(similar bool) { scrapeSimilar = similar }(scrapeSimilar)
+	scrapeSimilar = true
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The related apps have been discovered and scraped as well, but the compass does not exist
+	assert.Equal(t, 3, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM not_found_apps WHERE app_id = 3"))
+	assert.Len(t, scrapedApp(t, db, 1).SimilarApps, 2)
+}
+
+func TestScrapeSimilarError(t *testing.T) {
+	store := startFakeAppStore(t)
+	calculator := fakeApp(1, "Calculator", 6007)
+	calculator.Similar = []appstore.SimilarApp{{AppId: 3, Title: "Compass", View: appstore.ViewMoreByDeveloper}}
+	store.AddApp(calculator)
+	store.AddApp(fakeApp(2, "Clock", 6007))
+	store.Fail("/v1/catalog/US/apps/2", fakestore.FaultMalformed, -1)
+
+	defer func(similar bool) { scrapeSimilar = similar }(scrapeSimilar)
+	scrapeSimilar = true
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []int{1, 2} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both apps are kept, and the one without related apps is marked to be done again
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id IN (1, 2)"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 1 AND similar_error IS NULL"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 2 AND similar_error IS NOT NULL"))
+	assert.Len(t, scrapedApp(t, db, 1).SimilarApps, 1)
+}
+
+func TestScrapeSimilarRetry(t *testing.T) {
+	store := startFakeAppStore(t)
+	clock := fakeApp(2, "Clock", 6007)
+	clock.Similar = []appstore.SimilarApp{{AppId: 3, Title: "Compass", View: appstore.ViewMoreByDeveloper}}
+	store.AddApp(clock)
+	store.AddApp(fakeApp(3, "Compass", 6007))
+	store.Fail("/v1/catalog/US/apps/2", fakestore.FaultMalformed, -1)
+
+	defer func(similar bool) { scrapeSimilar = similar }(scrapeSimilar)
+	scrapeSimilar = true
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 2 AND similar_error IS NOT NULL"))
+
+	// The next scrape gets the related apps that failed, and then scrapes the compass
+	store.Clear()
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 2 AND similar_error IS NULL"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps WHERE app_id = 3"))
+	assert.Len(t, scrapedApp(t, db, 2).SimilarApps, 1)
+	assert.Equal(t, "Clock", scrapedApp(t, db, 2).Title)
+}
+
+func TestBackOff(t *testing.T) {
+	defer func(sleep time.Duration) { RateLimitedSleepTime = sleep }(RateLimitedSleepTime)
+	RateLimitedSleepTime = 200 * time.Millisecond
+
+	rateLimiter := rate.NewLimiter(rate.Every(10*time.Millisecond), 1)
+	backOff(rateLimiter)
+
+	// The next request waits for the pause...
+	start := time.Now()
+	if err := rateLimiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), RateLimitedSleepTime)
+
+	// ...and then the limiter goes back to its own limit and burst
+	assert.Equal(t, rate.Every(10*time.Millisecond), rateLimiter.Limit())
+	assert.Equal(t, 1, rateLimiter.Burst())
+	start = time.Now()
+	if err := rateLimiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Less(t, time.Since(start), RateLimitedSleepTime/2)
+}
+
+func TestScrapeExpiredToken(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+
+	// The token expires after it has been got, and a new one has to be got
+	store.Fail("/v1/catalog/", fakestore.FaultUnauthorized, 1)
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+}
+
+func TestSpider(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+	store.AddApp(fakeApp(2, "Clock", 6007))
+	store.AddApp(fakeApp(3, "Compass", 6007))
+	store.AddApp(fakeApp(4, "1Password", 6007))
+	store.AddApp(fakeApp(5, "Chess", 6014))
+
+	// An app that is only in the UK storefront
+	iPlayer := fakeApp(6, "BBC iPlayer", 6016)
+	iPlayer.Storefronts = []string{"gb"}
+	store.AddApp(iPlayer)
+
+	defaultStorefronts := storefronts
+	storefronts = []string{"us", "gb"}
+	defer func() { storefronts = defaultStorefronts }()
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+
+	// The spider stops when it is rate limited...
+	store.Fail("/us/genre/", fakestore.FaultRateLimited, -1)
+	err := spider(context.Background(), db)
+	assert.True(t, errors.Is(err, appstore.ErrRateLimited))
+
+	// ...and carries on where it left off
+	store.Clear()
+	if err := spider(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 6, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM spider_progress WHERE page_reached IS NOT NULL"))
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(DISTINCT storefront) FROM spider_progress"))
+}
+
+func TestSearch(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+	store.AddApp(fakeApp(2, "Clock", 6007))
+	store.AddApp(fakeApp(3, "Alarm Clock", 6007))
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := search(context.Background(), db, []string{"clock", "nothing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps WHERE app_id = 3"))
+}
+
+func TestSearchRateLimited(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Clock", 6007))
+
+	defer func(sleep time.Duration) { RateLimitedSleepTime = sleep }(RateLimitedSleepTime)
+	RateLimitedSleepTime = 100 * time.Millisecond
+
+	// The search backs off and tries again rather than giving up
+	store.Fail("/search", fakestore.FaultRateLimited, 1)
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if err := search(context.Background(), db, []string{"clock"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps WHERE app_id = 1"))
+}
+
+func TestDevelopers(t *testing.T) {
+	store := startFakeAppStore(t)
+	for _, app := range []struct {
+		appId       appstore.AppId
+		title       string
+		developerId int64
+	}{
+		{1, "Calculator", 100},
+		{2, "Clock", 100},
+		{3, "Compass", 100},
+		{4, "Chess", 200},
+	} {
+		fake := fakeApp(app.appId, app.title, 6007)
+		fake.Details.DeveloperId = app.developerId
+		store.AddApp(fake)
+	}
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scrape(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := developers(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other apps of the developer of the calculator, but not the chess app
+	assert.Equal(t, 3, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps WHERE app_id = 4"))
+}
+
+func TestImportBundleIds(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+	store.AddApp(fakeApp(2, "Clock", 6007))
+
+	input := filepath.Join(t.TempDir(), "bundle_ids.txt")
+	if err := os.WriteFile(input, []byte("com.example.appCalculator\nCOM.EXAMPLE.APPCLOCK\n\ncom.example.missing\ncom.example.appclock\ncom.example.appCalculator\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	imported, err := importBundleIds(context.Background(), db, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repeated bundle IDs are only counted once
+	assert.Equal(t, int64(2), imported)
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps WHERE app_id IN (1, 2)"))
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+}
+
+func TestImportBundleIdsChunks(t *testing.T) {
+	store := startFakeAppStore(t)
+	store.AddApp(fakeApp(1, "Calculator", 6007))
+	store.AddApp(fakeApp(2, "Clock", 6007))
+
+	// The clock is in the second chunk, which cannot be looked up
+	bundleIds := []string{"com.example.appCalculator"}
+	for i := 1; i < appstore.MaxLookupIds; i++ {
+		bundleIds = append(bundleIds, fmt.Sprintf("com.example.missing%d", i))
+	}
+	bundleIds = append(bundleIds, "com.example.appClock")
+	store.FailQuery("/lookup", "bundleId", "com.example.appClock", fakestore.FaultMalformed, -1)
+
+	input := filepath.Join(t.TempDir(), "bundle_ids.txt")
+	if err := os.WriteFile(input, []byte(strings.Join(bundleIds, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	err := Import(context.Background(), db, []string{input}, true)
+	assert.Error(t, err)
+
+	// The first chunk was imported before the second one failed
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps WHERE app_id = 1"))
+	assert.Equal(t, 1, dbtest.Count(t, db, "SELECT COUNT(*) FROM apps"))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+type PriceInfo struct {
+	Country   string
+	Available bool
+	Currency  string
+	Price     float64
+	// The iTunes lookup API does not say whether an app is on sale, so this is always null
+	OriginalPrice null.Float
+}
+
+// If an app is paid, then scrape its price in the additional storefronts as well. The apps
+// in each storefront are looked up together. Apps that are free in the primary storefront
+// are not looked up in the others. If a storefront cannot be scraped, then the apps have
+// no price in it, like in a storefront where they are not available, and the other
+// storefronts are kept.
+func scrapePrices(ctx context.Context, client *appstore.Client, progress *progressbar.ProgressBar, rateLimiter *rate.Limiter, scrapedApps []ScrapedApp) error {
+	var paidApps []*ScrapedApp
+
+	for i := range scrapedApps {
+		scrapedApp := &scrapedApps[i]
+		if scrapedApp.Price <= 0 {
+			continue
+		}
+
+		scrapedApp.prices = make([]PriceInfo, 1+len(additionalCountriesForPrice))
+
+		// Add price information for the primary storefront
+		scrapedApp.prices[0] = PriceInfo{
+			Country:   strings.ToLower(country),
+			Available: true,
+			Currency:  scrapedApp.Currency,
+			Price:     scrapedApp.Price,
+		}
+
+		paidApps = append(paidApps, scrapedApp)
+	}
+
+	if len(paidApps) == 0 {
+		return nil
+	}
+
+	appIds := make([]appstore.AppId, 0, len(paidApps))
+	for _, paidApp := range paidApps {
+		appIds = append(appIds, paidApp.AppId)
+	}
+
+	// Then scrape price information for the additional storefronts
+	errgrp, scrapeCtx := errgroup.WithContext(ctx)
+	for i, priceCountry := range additionalCountriesForPrice {
+		i, priceCountry := i, priceCountry
+		errgrp.Go(func() error {
+			appsDetails, err := withRateLimit(scrapeCtx, progress, rateLimiter, func() (map[appstore.AppId]appstore.Details, error) {
+				return appstore.ScrapeDetails(scrapeCtx, client, appIds, priceCountry, language)
+			})
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+
+				log.Printf("prices in %s: %v", priceCountry, err)
+				for _, paidApp := range paidApps {
+					paidApp.prices[i+1] = PriceInfo{Country: strings.ToLower(priceCountry)}
+				}
+				return nil
+			}
+
+			for _, paidApp := range paidApps {
+				// Apps that are not in a storefront are left out of the lookup
+				details, available := appsDetails[paidApp.AppId]
+				paidApp.prices[i+1] = PriceInfo{
+					Country:   strings.ToLower(priceCountry),
+					Available: available,
+					Currency:  details.Currency,
+					Price:     details.Price,
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return errgrp.Wait()
+}
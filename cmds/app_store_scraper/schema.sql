@@ -2,26 +2,48 @@ CREATE TABLE IF NOT EXISTS apps (
     app_id      INT PRIMARY KEY NOT NULL
 ) WITHOUT ROWID;
 
+-- Apps are scraped (or not found) once in each storefront
 CREATE TABLE IF NOT EXISTS scraped_apps (
-    scrape_id    INTEGER PRIMARY KEY,
-    app_id       INT NOT NULL UNIQUE REFERENCES apps(app_id),
-    scraped_when INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER)),
-    data         BLOB NOT NULL
+    scrape_id     INTEGER PRIMARY KEY,
+    app_id        INT NOT NULL REFERENCES apps(app_id),
+    country       TEXT NOT NULL CHECK (lower(country) = country),
+    scraped_when  INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER)),
+    data          BLOB NOT NULL,
+    -- Why the related apps could not be scraped with --similar. They are scraped again
+    -- the next time that scrape is run with --similar.
+    similar_error TEXT,
+    UNIQUE (app_id, country)
 );
 
 CREATE TABLE IF NOT EXISTS not_found_apps (
     not_found_id INTEGER PRIMARY KEY,
-    app_id       INT NOT NULL UNIQUE REFERENCES apps(app_id),
-    scraped_when INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER))
+    app_id       INT NOT NULL REFERENCES apps(app_id),
+    country      TEXT NOT NULL CHECK (lower(country) = country),
+    scraped_when INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER)),
+    UNIQUE (app_id, country)
+);
+
+CREATE TABLE IF NOT EXISTS prices (
+    scraped_when   INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER)),
+    app_id         INT NOT NULL REFERENCES apps(app_id),
+    country        TEXT NOT NULL CHECK (lower(country) = country),
+    currency       TEXT NOT NULL,
+    price          REAL NOT NULL CHECK (price >= 0),
+    -- The iTunes lookup API does not say whether an app is on sale, so this is always
+    -- NULL. It is kept so that the table has the same shape as the Play Store one.
+    original_price REAL,
+    PRIMARY KEY (app_id, country)
 );
 
 CREATE TABLE IF NOT EXISTS spider_progress (
+    storefront   TEXT NOT NULL CHECK (lower(storefront) = storefront),
     genre        INTEGER NOT NULL,
     letter       TEXT NOT NULL,
     page_reached INTEGER,
-    PRIMARY KEY (genre, letter)
+    PRIMARY KEY (storefront, genre, letter)
 );
 
+-- Other storefronts are added when they are first spidered
 INSERT INTO spider_progress
 WITH
     -- From https://github.com/facundoolano/app-store-scraper/blob/master/lib/constants.js#L19
@@ -126,5 +148,5 @@ WITH
                 ("Z"),
                 ("*")
     )
-SELECT genre, letter, 1 AS page_reached FROM genre, letter WHERE true
+SELECT 'us' AS storefront, genre, letter, 1 AS page_reached FROM genre, letter WHERE true
 ON CONFLICT DO NOTHING;
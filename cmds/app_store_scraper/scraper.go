@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"errors"
-	"net/http"
+	"log"
+	"math"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -14,18 +16,30 @@ import (
 )
 
 type ScrapedApp struct {
+	Country  string `json:"country"`
+	Language string `json:"language"`
 	appstore.Details
-	PrivacyNutritionLabels appstore.PrivacyNutritionLabels `json:"privacy_nutrition_labels"`
+	appstore.CatalogDetails
+	SimilarApps []appstore.SimilarApp `json:"similar,omitempty"`
+	prices      []PriceInfo
+	// Why the related apps could not be scraped, if they were to be
+	similarErr error
 }
 
-func Scrape(ctx context.Context, client *http.Client, progress *progressbar.ProgressBar, rateLimiter *rate.Limiter, token appstore.Token, scrapedAppsChan chan<- []ScrapedApp, notFoundAppsChan chan<- []appstore.AppId, appIds []appstore.AppId) error {
+// The apps that were not found in a storefront
+type NotFoundApps struct {
+	Country string
+	AppIds  []appstore.AppId
+}
+
+func Scrape(ctx context.Context, client *appstore.Client, progress *progressbar.ProgressBar, rateLimiter *rate.Limiter, similarRateLimiter *rate.Limiter, tokens *appstore.TokenManager, scrapedAppsChan chan<- []ScrapedApp, notFoundAppsChan chan<- NotFoundApps, appIds []appstore.AppId) error {
 	errgrp, scrapeCtx := errgroup.WithContext(ctx)
 
 	detailsChan := make(chan map[appstore.AppId]appstore.Details, 1)
-	privacyChan := make(chan map[appstore.AppId]appstore.PrivacyNutritionLabels, 1)
+	catalogChan := make(chan map[appstore.AppId]appstore.CatalogDetails, 1)
 
 	errgrp.Go(func() error {
-		details, err := appstore.ScrapeDetails(scrapeCtx, client, appIds)
+		details, err := appstore.ScrapeDetails(scrapeCtx, client, appIds, country, language)
 		if err != nil {
 			return err
 		}
@@ -35,46 +49,53 @@ func Scrape(ctx context.Context, client *http.Client, progress *progressbar.Prog
 	})
 
 	errgrp.Go(func() error {
-	PrivacyLoop:
-		for {
-			if err := rateLimiter.Wait(scrapeCtx); err != nil {
-				return err
-			}
-			privacy, err := appstore.ScrapePrivacy(scrapeCtx, client, token, appIds)
-			if err != nil {
-				if errors.Is(err, appstore.ErrRateLimited) {
-					progress.Describe("Rate limited")
-					rateLimiter.SetLimit(rate.Limit(0))
-					rateLimiter.SetLimitAt(time.Now().Add(RateLimitedSleepTime), rate.Every(RateLimit))
-					continue PrivacyLoop
-				} else {
-					return err
-				}
-			}
-
-			privacyChan <- privacy
-			return nil
+		catalog, err := withRateLimit(scrapeCtx, progress, rateLimiter, func() (map[appstore.AppId]appstore.CatalogDetails, error) {
+			return tokens.ScrapeCatalog(scrapeCtx, appIds, country, language)
+		})
+		if err != nil {
+			return err
 		}
+
+		catalogChan <- catalog
+		return nil
 	})
 
 	if err := errgrp.Wait(); err != nil {
 		return err
 	}
 
-	appsDetails, appsPrivacy := <-detailsChan, <-privacyChan
+	appsDetails, appsCatalog := <-detailsChan, <-catalogChan
 	scrapedApps := make([]ScrapedApp, 0, len(appsDetails))
-	notFoundApps := make([]appstore.AppId, 0, len(appIds)-len(appsDetails))
+	notFoundApps := NotFoundApps{Country: country, AppIds: make([]appstore.AppId, 0, len(appIds)-len(appsDetails))}
 
 	for _, appId := range appIds {
 		details, existsDetails := appsDetails[appId]
-		privacy, existsPrivacy := appsPrivacy[appId]
-		if existsDetails && existsPrivacy {
-			scrapedApps = append(scrapedApps, ScrapedApp{Details: details, PrivacyNutritionLabels: privacy})
+		catalog, existsCatalog := appsCatalog[appId]
+		if existsDetails && existsCatalog {
+			for _, problem := range catalog.Problems {
+				log.Printf("%d: catalog: %s", appId, problem)
+			}
+			scrapedApps = append(scrapedApps, ScrapedApp{Country: country, Language: language, Details: details, CatalogDetails: catalog})
 		} else {
-			notFoundApps = append(notFoundApps, appId)
+			notFoundApps.AppIds = append(notFoundApps.AppIds, appId)
 		}
 	}
 
+	// The prices and the related apps are scraped at the same time
+	errgrp, scrapeCtx = errgroup.WithContext(ctx)
+	errgrp.Go(func() error {
+		return scrapePrices(scrapeCtx, client, progress, rateLimiter, scrapedApps)
+	})
+	if scrapeSimilar {
+		errgrp.Go(func() error {
+			return scrapeSimilarApps(scrapeCtx, client, progress, similarRateLimiter, tokens, scrapedApps)
+		})
+	}
+
+	if err := errgrp.Wait(); err != nil {
+		return err
+	}
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -89,3 +110,43 @@ func Scrape(ctx context.Context, client *http.Client, progress *progressbar.Prog
 
 	return nil
 }
+
+// Serializes backing off, so that the burst is never read while it is raised
+var backOffMu sync.Mutex
+
+// Stop the rate limiter for at least RateLimitedSleepTime, after which it goes on at its
+// usual limit. This takes the tokens for RateLimitedSleepTime in advance, on top of the
+// tokens the limiter has, so the next request waits until they have come back. Requests
+// that were already waiting for the limiter are not held back.
+func backOff(rateLimiter *rate.Limiter) {
+	backOffMu.Lock()
+	defer backOffMu.Unlock()
+
+	burst := rateLimiter.Burst()
+	n := burst + int(math.Ceil(float64(rateLimiter.Limit())*RateLimitedSleepTime.Seconds()))
+
+	// A reservation can only take up to the burst
+	rateLimiter.SetBurst(n)
+	rateLimiter.ReserveN(time.Now(), n)
+	rateLimiter.SetBurst(burst)
+}
+
+// Call the amp-api when the rate limiter allows, and back off for a while if Apple rate
+// limits us anyway
+func withRateLimit[T any](ctx context.Context, progress *progressbar.ProgressBar, rateLimiter *rate.Limiter, call func() (T, error)) (T, error) {
+	for {
+		if err := rateLimiter.Wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		result, err := call()
+		if errors.Is(err, appstore.ErrRateLimited) {
+			progress.Describe("Rate limited")
+			backOff(rateLimiter)
+			continue
+		}
+
+		return result, err
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+// Number of search results to ask for for each term
+var searchLimit = appstore.MaxSearchLimit
+
+// Search the App Store for each of the terms and add the apps that are found to the apps
+// to scrape. This finds apps that are not listed on the genre pages that the spider crawls.
+func search(ctx context.Context, db *sql.DB, terms []string) error {
+	client := makeClient()
+	defer client.HTTPClient.CloseIdleConnections()
+	rateLimiter := rate.NewLimiter(rate.Every(RateLimit), 1)
+
+	progress := makeProgressBar(len(terms), "terms")
+
+	var found, added int64
+	for _, term := range terms {
+		term := term
+
+		progress.Describe(term)
+		results, err := withRateLimit(ctx, progress, rateLimiter, func() ([]appstore.Details, error) {
+			return appstore.Search(ctx, client, term, country, language, searchLimit, appstore.EntitySoftware, appstore.EntityIpadSoftware)
+		})
+		if err != nil {
+			return err
+		}
+
+		n, err := insertDiscoveredApps(ctx, db, results)
+		if err != nil {
+			return err
+		}
+
+		found += int64(len(results))
+		added += n
+		progress.Add(1)
+	}
+
+	log.Printf("Found %d apps, of which %d are new.", found, added)
+	return nil
+}
+
+// Add apps to the apps to scrape, returning the number that were not there already
+func insertDiscoveredApps(ctx context.Context, db *sql.DB, apps []appstore.Details) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	insertApp, err := tx.PrepareContext(ctx, "INSERT INTO apps (app_id) VALUES (?) ON CONFLICT DO NOTHING")
+	if err != nil {
+		return 0, err
+	}
+	defer insertApp.Close()
+
+	var n int64
+	for _, app := range apps {
+		result, err := insertApp.ExecContext(ctx, int64(app.AppId))
+		if err != nil {
+			return 0, err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += inserted
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
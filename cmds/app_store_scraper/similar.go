@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/andybalholm/brotli"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+// Scrape the related apps of each app, which takes one request for each app, with up to
+// SimilarConcurrency requests at the same time and at most one every SimilarRateLimit.
+// If the related apps of an app cannot be scraped, then the error is kept with the app,
+// so that retrySimilarApps can scrape them again later.
+func scrapeSimilarApps(
+	ctx context.Context,
+	client *appstore.Client,
+	progress *progressbar.ProgressBar,
+	rateLimiter *rate.Limiter,
+	tokens *appstore.TokenManager,
+	scrapedApps []ScrapedApp,
+) error {
+	errgrp, scrapeCtx := errgroup.WithContext(ctx)
+	requestLimit := make(chan struct{}, SimilarConcurrency)
+
+	for i := range scrapedApps {
+		scrapedApp := &scrapedApps[i]
+
+		errgrp.Go(func() error {
+			select {
+			case requestLimit <- struct{}{}:
+			case <-scrapeCtx.Done():
+				return scrapeCtx.Err()
+			}
+			defer func() { <-requestLimit }()
+
+			scrape := func() ([]appstore.SimilarApp, error) {
+				return tokens.ScrapeSimilar(scrapeCtx, scrapedApp.AppId, country, language)
+			}
+			similar, err := withRateLimit(scrapeCtx, progress, rateLimiter, scrape)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+
+				// The details are the important part, so keep them anyway
+				log.Printf("%d: similar apps: %v", scrapedApp.AppId, err)
+				scrapedApp.similarErr = err
+				return nil
+			}
+
+			scrapedApp.SimilarApps = similar
+			return nil
+		})
+	}
+
+	return errgrp.Wait()
+}
+
+// Scrape the related apps of the apps in the storefront again where they could not be
+// scraped before, and add them to the apps to scrape. Apps whose related apps still
+// cannot be scraped keep the new error.
+func retrySimilarApps(
+	ctx context.Context,
+	db *sql.DB,
+	client *appstore.Client,
+	progress *progressbar.ProgressBar,
+	rateLimiter *rate.Limiter,
+	tokens *appstore.TokenManager,
+) error {
+	scrapeIds, scrapedApps, err := dbSimilarErrors(ctx, db, country)
+	if err != nil {
+		return err
+	}
+
+	if len(scrapedApps) == 0 {
+		return nil
+	}
+
+	progress.Describe("Retrying related apps")
+	err = scrapeSimilarApps(ctx, client, progress, rateLimiter, tokens, scrapedApps)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, scrapedApp := range scrapedApps {
+		uncompressed, err := json.Marshal(scrapedApp)
+		if err != nil {
+			return err
+		}
+
+		compressed := &bytes.Buffer{}
+		if err := brotliCompress(compressed, uncompressed); err != nil {
+			return err
+		}
+
+		for _, similarApp := range scrapedApp.SimilarApps {
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO apps (app_id) VALUES (?) ON CONFLICT DO NOTHING",
+				similarApp.AppId)
+			if err != nil {
+				return err
+			}
+		}
+
+		var similarErr null.String
+		if scrapedApp.similarErr != nil {
+			similarErr = null.StringFrom(scrapedApp.similarErr.Error())
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE scraped_apps SET data = ?, similar_error = ? WHERE scrape_id = ?",
+			compressed.Bytes(), similarErr, scrapeIds[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// The apps scraped in the storefront whose related apps could not be scraped, with the
+// IDs of their rows in scraped_apps
+func dbSimilarErrors(
+	ctx context.Context,
+	db *sql.DB,
+	country string,
+) ([]int64, []ScrapedApp, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT scrape_id, data FROM scraped_apps "+
+			"WHERE country = ? AND similar_error IS NOT NULL",
+		country)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var scrapeIds []int64
+	var scrapedApps []ScrapedApp
+
+	for rows.Next() {
+		var scrapeId int64
+		var compressed []byte
+		if err := rows.Scan(&scrapeId, &compressed); err != nil {
+			return nil, nil, err
+		}
+
+		var scrapedApp ScrapedApp
+		decoder := json.NewDecoder(brotli.NewReader(bytes.NewReader(compressed)))
+		if err := decoder.Decode(&scrapedApp); err != nil {
+			return nil, nil, err
+		}
+
+		scrapeIds = append(scrapeIds, scrapeId)
+		scrapedApps = append(scrapedApps, scrapedApp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return scrapeIds, scrapedApps, nil
+}
@@ -1,4 +1,5 @@
 import argparse
+import datetime
 import io
 import sqlite3
 
@@ -11,9 +12,35 @@ DATA_CATEGORIES_T = pa.list_(pa.struct([
     ("data_types", pa.list_(pa.string()))
 ]))
 
-# country and language are stored as partitions
+HISTOGRAM_T = pa.struct([
+    ("1", pa.int64()),
+    ("2", pa.int64()),
+    ("3", pa.int64()),
+    ("4", pa.int64()),
+    ("5", pa.int64()),
+])
+
+VERSION_T = pa.struct([
+    ("version", pa.string()),
+    ("release_notes", pa.string()),
+    ("released", pa.timestamp("ns"))
+])
+
+IN_APP_PURCHASE_T = pa.struct([
+    ("id", pa.string()),
+    ("name", pa.string()),
+    ("price", pa.float64()),
+    ("formatted_price", pa.string()),
+    ("currency", pa.string()),
+    ("subscription", pa.bool_()),
+    ("subscription_period", pa.string())
+])
+
+# Apps are scraped once in each storefront, so the country tells the rows of an app apart
 SCHEMA = pa.schema([
     ("app_id", pa.int64()),
+    ("country", pa.string()),
+    ("language", pa.string()),
     ("scraped_when", pa.timestamp("ns")),
     ("bundle_id", pa.string()),
     ("title", pa.string()),
@@ -50,7 +77,19 @@ SCHEMA = pa.schema([
                 ("identifier", pa.string()),
                 ("data_categories", DATA_CATEGORIES_T)])))
         ]))
-    )
+    ),
+    ("version_history", pa.list_(VERSION_T)),
+    ("histogram", HISTOGRAM_T),
+    ("in_app_purchases", pa.list_(IN_APP_PURCHASE_T))
+])
+
+PRICE_SCHEMA = pa.schema([
+    pa.field("scraped_when", pa.timestamp("s"), nullable=False),
+    pa.field("app_id", pa.int64(), nullable=False),
+    pa.field("country", pa.string(), nullable=False),
+    pa.field("currency", pa.string(), nullable=False),
+    pa.field("price", pa.float64(), nullable=False),
+    pa.field("original_price", pa.float64(), nullable=True)
 ])
 
 def scraped_apps(conn: sqlite3.Connection):
@@ -73,7 +112,8 @@ if __name__ == "__main__":
     parser = argparse.ArgumentParser(description="Convert App Store scraped data to parquet.")
     parser.add_argument("--sqlite3-extension", type=str, default="sqlite3_tools")
     parser.add_argument("--database", type=str, required=True)
-    parser.add_argument("--output", type=str, required=True)
+    parser.add_argument("--output", help="Path of scraped apps Parquet file", type=str, required=True)
+    parser.add_argument("--output-prices", help="Path of prices Parquet file", type=str, required=True)
 
     args = parser.parse_args()
 
@@ -111,5 +151,41 @@ if __name__ == "__main__":
         print("Writing parquet file...")
         pq.write_table(tbl, args.output, compression="ZSTD", compression_level=19)
 
+        del(buf)
+
+        # Now extract price information, in the same shape as for the Play Store
+
+        print("Reading prices...")
+        rows = conn.execute(
+            """
+            SELECT
+                datetime(scraped_when, 'unixepoch'),
+                app_id,
+                country,
+                currency,
+                price,
+                original_price
+            FROM
+                prices
+            """
+        ).fetchall()
+
+        print("Creating arrow table...")
+        scraped_when, app_id, country, currency, price, original_price = zip(*rows) if rows else ([], [], [], [], [], [])
+        tbl = pa.Table.from_pydict(
+            {
+                "scraped_when": [datetime.datetime.fromisoformat(dt) for dt in scraped_when],
+                "app_id": app_id,
+                "country": country,
+                "currency": currency,
+                "price": price,
+                "original_price": original_price
+            },
+            schema=PRICE_SCHEMA
+        )
+
+        print("Writing parquet file...")
+        pq.write_table(tbl, args.output_prices, compression="ZSTD", compression_level=19)
+
     finally:
         conn.close()
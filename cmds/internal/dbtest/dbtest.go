@@ -0,0 +1,38 @@
+// Package dbtest has helpers for the tests of the scrapers that use a database.
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+
+	"cmds/internal/database"
+)
+
+// Open an in-memory database for a store with the schema, which is closed at the end of
+// the test
+func Open(t testing.TB, store uint8, version uint8, schema string) *sql.DB {
+	t.Helper()
+
+	db, err := database.OpenMemory(store, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Run a query that counts something, e.g. SELECT COUNT(*) FROM apps
+func Count(t testing.TB, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
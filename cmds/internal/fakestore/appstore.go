@@ -0,0 +1,580 @@
+package fakestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/appstore"
+)
+
+// An app on the fake App Store. The app is listed on the genre pages of its GenreIds.
+type AppStoreApp struct {
+	Details        appstore.Details
+	Privacy        appstore.PrivacyNutritionLabels
+	VersionHistory []appstore.Version
+	Histogram      appstore.Histogram
+	Similar        []appstore.SimilarApp
+	InAppPurchases []appstore.InAppPurchase
+
+	// The storefronts the app is available in, or all of them if empty
+	Storefronts []string
+	// Prices in other storefronts, instead of Details.Price and Details.Currency
+	Prices map[string]Price
+}
+
+type Price struct {
+	Price    float64
+	Currency string
+}
+
+func (app *AppStoreApp) availableIn(storefront string) bool {
+	if len(app.Storefronts) == 0 {
+		return true
+	}
+
+	for _, s := range app.Storefronts {
+		if strings.EqualFold(s, storefront) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A fake App Store, with the iTunes lookup and search APIs, the amp-api catalog, the page
+// that the amp-api token is taken from and the genre pages. Apps that have not been added
+// are left out of the responses, like Apple does.
+type AppStore struct {
+	*httptest.Server
+	faults
+
+	// The token that the amp-api expects
+	Token string
+	// Number of apps on each genre page
+	PageSize int
+
+	mu   sync.Mutex
+	apps map[appstore.AppId]AppStoreApp
+}
+
+func NewAppStore() *AppStore {
+	s := &AppStore{
+		Token:    "fake-token",
+		PageSize: 2,
+		apps:     make(map[appstore.AppId]AppStoreApp),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup", s.handleLookup)
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/v1/catalog/", s.handleCatalog)
+	mux.HandleFunc("/", s.handleWebsite)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *AppStore) AddApp(app AppStoreApp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps[app.Details.AppId] = app
+}
+
+// The apps with the given IDs that exist in the storefront, in the order of the IDs
+func (s *AppStore) lookupIds(ids string, storefront string) []AppStoreApp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []AppStoreApp
+	for _, id := range strings.Split(ids, ",") {
+		appId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		if app, ok := s.apps[appstore.AppId(appId)]; ok && app.availableIn(storefront) {
+			apps = append(apps, app)
+		}
+	}
+
+	return apps
+}
+
+// The apps with the given bundle IDs, ignoring case, that exist in the storefront
+func (s *AppStore) lookupBundleIds(bundleIds string, storefront string) []AppStoreApp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []AppStoreApp
+	for _, bundleId := range strings.Split(bundleIds, ",") {
+		for _, app := range s.apps {
+			if strings.EqualFold(app.Details.BundleId, bundleId) && app.availableIn(storefront) {
+				apps = append(apps, app)
+			}
+		}
+	}
+
+	return apps
+}
+
+func (s *AppStore) handleLookup(w http.ResponseWriter, r *http.Request) {
+	fault := s.next(r)
+	if writeFault(w, fault) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	if fault == FaultMalformed {
+		fmt.Fprint(w, `{"resultCount":1,"results":[{"trackId":"`)
+		return
+	}
+
+	storefront := r.URL.Query().Get("country")
+
+	apps := s.lookupIds(r.URL.Query().Get("id"), storefront)
+	if bundleIds := r.URL.Query().Get("bundleId"); bundleIds != "" {
+		apps = append(apps, s.lookupBundleIds(bundleIds, storefront)...)
+	}
+
+	results := []interface{}{}
+	for _, app := range apps {
+		details := app.Details
+		if price, ok := app.Prices[strings.ToLower(storefront)]; ok {
+			details.Price, details.Currency = price.Price, price.Currency
+		}
+		results = append(results, lookupResult(&details))
+	}
+
+	// The IDs can be developers too, who come before their apps
+	for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+		developerId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		apps := s.developerApps(developerId)
+		if len(apps) == 0 {
+			continue
+		}
+
+		results = append(results, map[string]interface{}{
+			"wrapperType": "artist",
+			"artistType":  "Software Artist",
+			"artistId":    developerId,
+			"artistName":  apps[0].Details.Developer,
+		})
+		for _, app := range apps {
+			if app.availableIn(storefront) {
+				results = append(results, lookupResult(&app.Details))
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resultCount": len(results),
+		"results":     results,
+	})
+}
+
+// The apps of a developer in any storefront, in order of app ID
+func (s *AppStore) developerApps(developerId int64) []AppStoreApp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []AppStoreApp
+	for _, app := range s.apps {
+		if app.Details.DeveloperId == developerId {
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Details.AppId < apps[j].Details.AppId
+	})
+
+	return apps
+}
+
+func (s *AppStore) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if writeFault(w, s.next(r)) {
+		return
+	}
+
+	q := r.URL.Query()
+	term := strings.ToLower(q.Get("term"))
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+
+	results := []interface{}{}
+	for _, app := range s.searchApps(term, q.Get("country")) {
+		if len(results) == limit {
+			break
+		}
+		results = append(results, lookupResult(&app.Details))
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resultCount": len(results),
+		"results":     results,
+	})
+}
+
+// The apps in the storefront whose titles contain the term, in order of title
+func (s *AppStore) searchApps(term string, storefront string) []AppStoreApp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []AppStoreApp
+	for _, app := range s.apps {
+		if app.availableIn(storefront) && strings.Contains(strings.ToLower(app.Details.Title), term) {
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Details.Title < apps[j].Details.Title
+	})
+
+	return apps
+}
+
+func lookupResult(d *appstore.Details) map[string]interface{} {
+	genreIds := make([]string, 0, len(d.GenreIds))
+	for _, genreId := range d.GenreIds {
+		genreIds = append(genreIds, strconv.FormatInt(genreId, 10))
+	}
+
+	return map[string]interface{}{
+		"wrapperType":                        "software",
+		"kind":                               "software",
+		"trackId":                            d.AppId,
+		"bundleId":                           d.BundleId,
+		"trackName":                          d.Title,
+		"trackViewUrl":                       d.Url,
+		"description":                        d.Description,
+		"artworkUrl512":                      d.Icon,
+		"genres":                             d.Genres,
+		"genreIds":                           genreIds,
+		"primaryGenreName":                   d.PrimaryGenre,
+		"primaryGenreId":                     d.PrimaryGenreId,
+		"contentAdvisoryRating":              d.ContentRating,
+		"advisories":                         d.ContentAdvisories,
+		"languageCodesISO2A":                 d.Languages,
+		"fileSizeBytes":                      strconv.FormatInt(d.Size, 10),
+		"minimumOsVersion":                   d.RequiredOsVersion,
+		"releaseDate":                        d.Released,
+		"currentVersionReleaseDate":          d.Updated,
+		"releaseNotes":                       d.ReleaseNotes,
+		"version":                            d.Version,
+		"price":                              d.Price,
+		"currency":                           d.Currency,
+		"artistId":                           d.DeveloperId,
+		"artistName":                         d.Developer,
+		"artistViewUrl":                      d.DeveloperUrl,
+		"sellerUrl":                          d.DeveloperWebsite,
+		"averageUserRating":                  d.Score,
+		"userRatingCount":                    d.Reviews,
+		"averageUserRatingForCurrentVersion": d.CurrentVersionScore,
+		"userRatingCountForCurrentVersion":   d.CurrentVersionReviews,
+		"screenshotUrls":                     d.Screenshots,
+		"ipadScreenshotUrls":                 d.IpadScreenshots,
+		"appletvScreenshotUrls":              d.AppletvScreenshots,
+		"supportedDevices":                   d.SupportedDevices,
+	}
+}
+
+func (s *AppStore) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, `{"errors":[{"status":"401","title":"Unauthorized"}]}`, http.StatusUnauthorized)
+		return
+	}
+
+	fault := s.next(r)
+	if writeFault(w, fault) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if fault == FaultMalformed {
+		fmt.Fprint(w, `{"data":[{"id":"`)
+		return
+	}
+
+	if matches := catalogAppPathRe.FindStringSubmatch(r.URL.Path); matches != nil {
+		s.handleSimilar(w, matches[1], matches[2])
+		return
+	}
+
+	storefront := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/catalog/"), "/apps")
+
+	data := []interface{}{}
+	for _, app := range s.lookupIds(r.URL.Query().Get("ids"), storefront) {
+		privacyTypes := []interface{}{}
+		for _, privacyType := range app.Privacy {
+			privacyTypes = append(privacyTypes, rawPrivacyType(privacyType))
+		}
+
+		versionHistory := []interface{}{}
+		for _, version := range app.VersionHistory {
+			rawVersion := map[string]interface{}{
+				"versionDisplay": version.Version,
+				"releaseNotes":   version.ReleaseNotes,
+			}
+			if version.Released.Valid {
+				rawVersion["releaseDate"] = version.Released.Time.Format("2006-01-02")
+				rawVersion["releaseTimestamp"] = version.Released.Time.UTC().Format(time.RFC3339)
+			}
+			versionHistory = append(versionHistory, rawVersion)
+		}
+
+		inApps := []interface{}{}
+		for i, inApp := range app.InAppPurchases {
+			offer := map[string]interface{}{
+				"price":          inApp.Price,
+				"priceFormatted": inApp.FormattedPrice,
+				"currencyCode":   inApp.Currency,
+			}
+			if inApp.SubscriptionPeriod != "" {
+				offer["recurringSubscriptionPeriod"] = inApp.SubscriptionPeriod
+			}
+			inApps = append(inApps, map[string]interface{}{
+				"id":   strconv.Itoa(i + 1),
+				"type": "in-apps",
+				"attributes": map[string]interface{}{
+					"offerName":      inApp.Id,
+					"name":           inApp.Name,
+					"isSubscription": inApp.Subscription,
+					"offers":         []interface{}{offer},
+				},
+			})
+		}
+
+		h := app.Histogram
+		data = append(data, map[string]interface{}{
+			"id":   strconv.FormatInt(int64(app.Details.AppId), 10),
+			"type": "apps",
+			"attributes": map[string]interface{}{
+				"privacyDetails": map[string]interface{}{
+					"privacyTypes": privacyTypes,
+				},
+				"userRating": map[string]interface{}{
+					"ratingCountList": []int64{h.Stars1, h.Stars2, h.Stars3, h.Stars4, h.Stars5},
+				},
+				"platformAttributes": map[string]interface{}{
+					"ios": map[string]interface{}{
+						"versionHistory": versionHistory,
+					},
+				},
+			},
+			"relationships": map[string]interface{}{
+				"top-in-apps": map[string]interface{}{
+					"data": inApps,
+				},
+			},
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+var catalogAppPathRe = regexp.MustCompile(`^/v1/catalog/([A-Za-z]{2})/apps/(\d+)$`)
+
+// The related apps of one app, in the views that they are in
+func (s *AppStore) handleSimilar(w http.ResponseWriter, storefront string, appId string) {
+	apps := s.lookupIds(appId, storefront)
+	if len(apps) == 0 {
+		http.Error(w, `{"errors":[{"status":"404","title":"Resource Not Found"}]}`, http.StatusNotFound)
+		return
+	}
+
+	views := map[string]interface{}{}
+	for _, view := range []appstore.SimilarView{appstore.ViewCustomersAlsoBought, appstore.ViewMoreByDeveloper} {
+		data := []interface{}{}
+		for _, similar := range apps[0].Similar {
+			if similar.View != view {
+				continue
+			}
+			data = append(data, map[string]interface{}{
+				"id":   strconv.FormatInt(int64(similar.AppId), 10),
+				"type": "apps",
+				"attributes": map[string]interface{}{
+					"name":       similar.Title,
+					"artistName": similar.Developer,
+				},
+			})
+		}
+		views[string(view)] = map[string]interface{}{"data": data}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"id":    appId,
+				"type":  "apps",
+				"views": views,
+			},
+		},
+	})
+}
+
+func rawDataCategories(dataCategories []appstore.PrivacyDataCategories) []interface{} {
+	raw := []interface{}{}
+	for _, dataCategory := range dataCategories {
+		raw = append(raw, map[string]interface{}{
+			"identifier": dataCategory.Identifier,
+			"dataTypes":  dataCategory.DataTypes,
+		})
+	}
+	return raw
+}
+
+func rawPrivacyType(privacyType appstore.PrivacyType) map[string]interface{} {
+	purposes := []interface{}{}
+	for _, purpose := range privacyType.Purposes {
+		purposes = append(purposes, map[string]interface{}{
+			"identifier":     purpose.Identifier,
+			"dataCategories": rawDataCategories(purpose.DataCategories),
+		})
+	}
+
+	return map[string]interface{}{
+		"identifier":     privacyType.Identifier,
+		"dataCategories": rawDataCategories(privacyType.DataCategories),
+		"purposes":       purposes,
+	}
+}
+
+func (s *AppStore) handleTokenPage(w http.ResponseWriter, r *http.Request) {
+	if writeFault(w, s.next(r)) {
+		return
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"MEDIA_API": map[string]string{"token": s.Token},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta name="web-experience-app/config/environment" content="%s"></head><body></body></html>`,
+		html.EscapeString(url.QueryEscape(string(config))))
+}
+
+var tokenPathRe = regexp.MustCompile(`^/[a-z]{2}/developer/`)
+
+// The website of every storefront has the page with the token and the genre pages
+func (s *AppStore) handleWebsite(w http.ResponseWriter, r *http.Request) {
+	if tokenPathRe.MatchString(r.URL.Path) {
+		s.handleTokenPage(w, r)
+	} else {
+		s.handleGenrePage(w, r)
+	}
+}
+
+var genrePathRe = regexp.MustCompile(`^/([a-z]{2})/genre/(?:[^/]+/)?id(\d+)$`)
+
+func (s *AppStore) handleGenrePage(w http.ResponseWriter, r *http.Request) {
+	fault := s.next(r)
+	if writeFault(w, fault) {
+		return
+	}
+
+	matches := genrePathRe.FindStringSubmatch(r.URL.Path)
+	if matches == nil {
+		http.NotFound(w, r)
+		return
+	}
+	storefront := matches[1]
+	genre, _ := strconv.ParseInt(matches[2], 10, 64)
+
+	letter := r.URL.Query().Get("letter")
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if fault == FaultMalformed {
+		fmt.Fprintf(w, `<!DOCTYPE html><html><body><div id="selectedcontent"><a href="%s/us/app/`, s.URL)
+		return
+	}
+
+	apps := s.genreApps(storefront, genre, letter)
+	start := (page - 1) * s.PageSize
+	end := start + s.PageSize
+	if start > len(apps) {
+		start = len(apps)
+	}
+	if end > len(apps) {
+		end = len(apps)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html><html><body><div id="selectedcontent"><ul>`)
+	for _, app := range apps[start:end] {
+		// The app links point at the website they are on, like they do on the real pages
+		fmt.Fprintf(&sb, `<li><a href="%s/%s/app/%s/id%d">%s</a></li>`,
+			s.URL, storefront, url.PathEscape(strings.ToLower(strings.ReplaceAll(app.Details.Title, " ", "-"))), app.Details.AppId, html.EscapeString(app.Details.Title))
+	}
+	sb.WriteString(`</ul></div>`)
+	if end < len(apps) {
+		fmt.Fprintf(&sb, `<a href="%s/%s/genre/ios-genre/id%d?letter=%s&amp;page=%d#page" class="paginate-more">Next</a>`,
+			s.URL, storefront, genre, url.QueryEscape(letter), page+1)
+	}
+	sb.WriteString(`</body></html>`)
+
+	fmt.Fprint(w, sb.String())
+}
+
+// The apps in a genre whose titles start with the letter, or with something other than a
+// letter for *
+func (s *AppStore) genreApps(storefront string, genre int64, letter string) []AppStoreApp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []AppStoreApp
+	for _, app := range s.apps {
+		inGenre := false
+		for _, genreId := range app.Details.GenreIds {
+			if genreId == genre {
+				inGenre = true
+				break
+			}
+		}
+		if !inGenre || !app.availableIn(storefront) {
+			continue
+		}
+
+		first := strings.ToUpper(app.Details.Title)
+		if first != "" {
+			first = first[:1]
+		}
+		if first < "A" || first > "Z" {
+			first = "*"
+		}
+		if first == letter {
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Details.Title < apps[j].Details.Title
+	})
+
+	return apps
+}
@@ -0,0 +1,136 @@
+// Package fakestore has fake Play Store and App Store servers, so that the scrapers can
+// be tested end to end without touching the real stores. The servers can be told to
+// misbehave like the real ones do: rate limiting, captcha pages and malformed responses.
+package fakestore
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A way in which a server can misbehave
+type Fault int
+
+const (
+	// Respond with 429 Too Many Requests
+	FaultRateLimited Fault = iota + 1
+	// Respond with an HTML captcha page, like Google does for unusual traffic
+	FaultCaptcha
+	// Respond with a payload that does not have the expected structure
+	FaultMalformed
+	// Respond with 401 Unauthorized, like the amp-api does when the token has expired
+	FaultUnauthorized
+)
+
+const captchaPage = `<!DOCTYPE html><html><head><title>Sorry...</title></head><body>Our systems have detected unusual traffic from your computer network.</body></html>`
+
+type fault struct {
+	pathPrefix string
+	// The query parameter that the requests must also have, if any
+	queryKey, queryValue string
+	fault                Fault
+	remaining            int
+}
+
+// Faults to inject into the responses of a server
+type faults struct {
+	mu     sync.Mutex
+	faults []*fault
+}
+
+// Make the next n requests whose path starts with pathPrefix fail with the fault. If n
+// is negative, the requests fail until Clear is called.
+func (f *faults) Fail(pathPrefix string, kind Fault, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = append(f.faults, &fault{pathPrefix: pathPrefix, fault: kind, remaining: n})
+}
+
+// Like Fail, but only for the requests that also have the query parameter key=value, e.g.
+// the lookups in one storefront
+func (f *faults) FailQuery(pathPrefix string, key string, value string, kind Fault, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = append(f.faults, &fault{pathPrefix: pathPrefix, queryKey: key, queryValue: value, fault: kind, remaining: n})
+}
+
+// Stop failing requests
+func (f *faults) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = nil
+}
+
+// The fault for a request, or zero if the request should succeed
+func (f *faults) next(r *http.Request) Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, fault := range f.faults {
+		if !strings.HasPrefix(r.URL.Path, fault.pathPrefix) || fault.remaining == 0 {
+			continue
+		}
+		if fault.queryKey != "" && r.URL.Query().Get(fault.queryKey) != fault.queryValue {
+			continue
+		}
+
+		if fault.remaining > 0 {
+			fault.remaining--
+		}
+		return fault.fault
+	}
+
+	return 0
+}
+
+// Respond to rate limiting, captcha and authorization faults, which look the same for all
+// endpoints. Returns true if the response has been written.
+func writeFault(w http.ResponseWriter, fault Fault) bool {
+	switch fault {
+	case FaultRateLimited:
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(captchaPage))
+		return true
+
+	case FaultCaptcha:
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write([]byte(captchaPage))
+		return true
+
+	case FaultUnauthorized:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"status":"401","title":"Unauthorized"}]}`))
+		return true
+	}
+
+	return false
+}
+
+// Put value at a gjson-style path of numeric keys, e.g. 1.2.0.0, growing the arrays as
+// needed
+func setPath(v interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+
+	index, err := strconv.Atoi(path[0])
+	if err != nil {
+		panic(err)
+	}
+
+	array, _ := v.([]interface{})
+	for len(array) <= index {
+		array = append(array, nil)
+	}
+	array[index] = setPath(array[index], path[1:], value)
+
+	return array
+}
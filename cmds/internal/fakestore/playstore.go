@@ -0,0 +1,328 @@
+package fakestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/playstore"
+)
+
+// An app on the fake Play Store
+type PlayApp struct {
+	AppId   string
+	Details playstore.Details
+	Similar []playstore.SimilarApp
+
+	// The countries in which the details do not have the expected structure
+	MalformedIn []string
+	// The countries in which there is no response to the details request
+	UnansweredIn []string
+	// The countries in which Google rejects every batch that asks for the app
+	RejectedIn []string
+}
+
+// A fake Play Store, which answers the batchexecute requests for details, similar apps
+// and data safety. Apps that have not been added are reported as not found.
+type PlayStore struct {
+	*httptest.Server
+	faults
+
+	mu          sync.Mutex
+	apps        map[string]PlayApp
+	numRequests int
+}
+
+func NewPlayStore() *PlayStore {
+	s := &PlayStore{apps: make(map[string]PlayApp)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/store/apps", s.handleStore)
+	mux.HandleFunc("/_/PlayStoreUi/data/batchexecute", s.handleBatchExecute)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *PlayStore) AddApp(app PlayApp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps[app.AppId] = app
+}
+
+// The number of batchexecute requests that have been made
+func (s *PlayStore) NumRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.numRequests
+}
+
+// The page that the session parameters are taken from
+func (s *PlayStore) handleStore(w http.ResponseWriter, r *http.Request) {
+	if writeFault(w, s.next(r)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	fmt.Fprint(w, `<!DOCTYPE html><html><head><script>window.WIZ_global_data = {"FdrFJe":"-1234567890123456789","cfb2h":"boq_playuiserver_20220101.00_p0"};</script></head><body></body></html>`)
+}
+
+var appIdRe = regexp.MustCompile(`\["([^"]+)",7\]`)
+
+func (s *PlayStore) handleBatchExecute(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.numRequests++
+	s.mu.Unlock()
+
+	fault := s.next(r)
+	if writeFault(w, fault) {
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var fReq [][][]*string
+	if err := json.Unmarshal([]byte(r.PostForm.Get("f.req")), &fReq); err != nil || len(fReq) != 1 {
+		http.Error(w, "invalid f.req", http.StatusBadRequest)
+		return
+	}
+
+	country := r.URL.Query().Get("gl")
+
+	var envelopes []interface{}
+	for _, request := range fReq[0] {
+		if len(request) != 4 || request[0] == nil || request[1] == nil || request[3] == nil {
+			http.Error(w, "invalid request in f.req", http.StatusBadRequest)
+			return
+		}
+		rpcId, payload, number := *request[0], *request[1], *request[3]
+
+		var app PlayApp
+		if matches := appIdRe.FindStringSubmatch(payload); matches != nil {
+			s.mu.Lock()
+			app = s.apps[matches[1]]
+			s.mu.Unlock()
+		}
+
+		if containsFold(app.RejectedIn, country) {
+			envelopes = []interface{}{[]interface{}{"er", nil, nil, nil, nil, 400, nil, nil, nil, 3}}
+			break
+		}
+		if rpcId == "Ws7gDc" && containsFold(app.UnansweredIn, country) {
+			continue
+		}
+
+		var response interface{}
+		if fault == FaultMalformed {
+			response = `[null,[1,2,3]]`
+		} else if app.AppId != "" {
+			response = s.respond(rpcId, app, country)
+		}
+
+		// Like Google, report missing apps as not found without saying which request it was
+		if response == nil {
+			envelopes = append(envelopes, []interface{}{"wrb.fr", rpcId, nil, nil, nil, []interface{}{5, nil, []interface{}{}}, "generic"})
+			continue
+		}
+
+		envelopes = append(envelopes, []interface{}{"wrb.fr", rpcId, response, nil, nil, nil, number})
+	}
+	envelopes = append(envelopes, []interface{}{"di", 42}, []interface{}{"af.httprm", 42, "-1234567890", 1})
+
+	body, err := json.Marshal(envelopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(")]}'\n\n"))
+	w.Write(body)
+}
+
+func containsFold(countries []string, country string) bool {
+	for _, c := range countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// The payload for a request for an app that exists, or nil if there is no such RPC
+func (s *PlayStore) respond(rpcId string, app PlayApp, country string) interface{} {
+	if rpcId == "Ws7gDc" && containsFold(app.MalformedIn, country) {
+		return `[null,[1,2,3]]`
+	}
+
+	var payload interface{}
+	switch rpcId {
+	case "Ws7gDc":
+		// Details and data safety use the same RPC. There is no data safety section, so
+		// the apps do not have any data safety information.
+		payload = detailsPayload(app)
+
+	case "ag2B9c":
+		similarApps := make([]interface{}, 0, len(app.Similar))
+		for _, similarApp := range app.Similar {
+			similarApps = append(similarApps, encode(&similarApp))
+		}
+		payload = setPath(nil, []string{"1", "1", "1", "21", "0"}, similarApps)
+
+	default:
+		return nil
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	return string(raw)
+}
+
+func detailsPayload(app PlayApp) interface{} {
+	details := app.Details
+	payload := encode(&details)
+
+	set := func(path string, value interface{}) {
+		payload = setPath(payload, strings.Split(path, "."), value)
+	}
+
+	// These are not extracted with struct tags
+	available := 3
+	if details.Available {
+		available = 1
+	}
+	set("1.2.42.0", available)
+
+	developerId := details.DeveloperId
+	if developerId == "" {
+		developerId = "5509190841173705883"
+	}
+	set("1.2.68.1.4.2", "/store/apps/dev?id="+developerId)
+
+	if details.AdSupported {
+		set("1.2.48.0", "Contains ads")
+	}
+
+	groups, other := []interface{}{}, []interface{}{}
+	for _, permission := range details.Permissions {
+		if permission.Group == "Other" {
+			other = append(other, []interface{}{nil, permission.Permission})
+		} else {
+			groups = append(groups, []interface{}{permission.Group, nil, []interface{}{[]interface{}{nil, permission.Permission}}, nil})
+		}
+	}
+	set("1.2.74.2", []interface{}{groups, other})
+
+	return payload
+}
+
+// The inverse of the struct tag decoding in playstore: make a payload with the tagged
+// fields of v at their paths
+func encode(v interface{}) interface{} {
+	var payload interface{}
+	rv := reflect.ValueOf(v).Elem()
+
+	for _, fieldPath := range playstore.Paths(v) {
+		field := rv
+		for _, name := range strings.Split(fieldPath.Field, ".") {
+			field = field.FieldByName(name)
+		}
+
+		path := fieldPath.Path
+
+		// Slices along a path with a # have one element at each index
+		if i := strings.Index(path, "#"); i >= 0 {
+			for j := 0; j < field.Len(); j++ {
+				value := encodeValue(field.Index(j).Interface(), fieldPath.Micros)
+				if value == nil {
+					continue
+				}
+				elementPath := path[:i] + strconv.Itoa(j) + path[i+1:]
+				payload = setPath(payload, strings.Split(elementPath, "."), value)
+			}
+			continue
+		}
+
+		value := encodeValue(field.Interface(), fieldPath.Micros)
+		if value == nil {
+			// Missing values are extracted as null
+			continue
+		}
+		payload = setPath(payload, strings.Split(path, "."), value)
+	}
+
+	return payload
+}
+
+func encodeValue(v interface{}, micros bool) interface{} {
+	scale := func(x float64) float64 {
+		if micros {
+			return x * 1000000
+		}
+		return x
+	}
+
+	switch x := v.(type) {
+	case string, int64, bool:
+		return x
+	case float64:
+		return scale(x)
+	case time.Time:
+		return x.Unix()
+	case null.String:
+		if x.Valid {
+			return x.String
+		}
+	case null.Int:
+		if x.Valid {
+			return x.Int64
+		}
+	case null.Float:
+		if x.Valid {
+			return scale(x.Float64)
+		}
+	case null.Bool:
+		if x.Valid {
+			return x.Bool
+		}
+	case null.Time:
+		if x.Valid {
+			return x.Time.Unix()
+		}
+	case []string:
+		return x
+	case []float64:
+		return x
+	case []null.Float:
+		values := make([]interface{}, 0, len(x))
+		for _, f := range x {
+			values = append(values, encodeValue(f, micros))
+		}
+		return values
+	default:
+		panic(fmt.Sprintf("cannot encode %T", v))
+	}
+
+	return nil
+}
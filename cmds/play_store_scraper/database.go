@@ -5,8 +5,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/andybalholm/brotli"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/playstore"
 )
 
 type preparedStatements struct {
@@ -148,6 +151,57 @@ func insertNotFoundApp(ctx context.Context, db *sql.DB, stmts *preparedStatement
 	return tx.Commit()
 }
 
+// Save the statistics of the extracted paths, so that runs can be compared
+func insertHealthReport(ctx context.Context, db *sql.DB, started time.Time, stats []playstore.PathStats) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO scrape_runs (scrape_started) VALUES (?)", started.Unix())
+	if err != nil {
+		return err
+	}
+
+	runId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO extraction_health (run_id, field, path, observed, missing, null_values, wrong_type, failures, drifted)
+	VALUES (:run_id, :field, :path, :observed, :missing, :null_values, :wrong_type, :failures, :drifted)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range stats {
+		args := []interface{}{
+			sql.Named("run_id", runId),
+			sql.Named("field", s.Field),
+			sql.Named("path", s.Path),
+			sql.Named("observed", s.Observed),
+			sql.Named("missing", s.Missing),
+			sql.Named("null_values", s.Null),
+			sql.Named("wrong_type", s.WrongType),
+			sql.Named("failures", s.Failures),
+			sql.Named("drifted", s.Drifted),
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func brotliCompress(dst *bytes.Buffer, src []byte) error {
 	compressor := brotli.NewWriterLevel(dst, 5)
 	if _, err := compressor.Write(src); err != nil {
@@ -13,10 +13,12 @@ import (
 	"cmds/internal/database"
 
 	"github.com/spf13/cobra"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/playstore"
 )
 
 const (
-	DatabaseVersion uint8 = 2
+	DatabaseVersion uint8 = 3
 	QueueSize       int   = 1_000
 )
 
@@ -24,6 +26,7 @@ var scrapeConfig ScrapeConfig = ScrapeConfig{
 	Language:                    "en",
 	Country:                     "us",
 	AdditionalCountriesForPrice: []string{"gb", "de", "fr", "it", "ru", "jp", "in", "br"},
+	BaseUrl:                     playstore.DefaultBaseUrl,
 }
 
 //go:embed schema.sql
@@ -123,6 +126,8 @@ func main() {
 		},
 	}
 	scrapeCmd.Flags().IntVar(&numScrapers, "num-scrapers", 20, "Number of simultaneous scrapers")
+	scrapeCmd.Flags().IntVar(&scrapeConfig.MaxBatchSize, "max-batch-size", playstore.DefaultMaxBatchSize, "Maximum number of requests in one HTTP request")
+	scrapeCmd.Flags().StringVar(&scrapeConfig.BaseUrl, "play-store-url", scrapeConfig.BaseUrl, "Address of the Play Store (e.g. a fake one for testing)")
 	rootCmd.AddCommand(scrapeCmd)
 
 	rootCmd.Execute()
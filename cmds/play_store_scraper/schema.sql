@@ -24,3 +24,24 @@ CREATE TABLE IF NOT EXISTS prices (
     original_price REAL,
     PRIMARY KEY (app_id, country)
 );
+
+-- Every scrape that has saved an extraction health report. Scrapes can start in the same
+-- second, so they are told apart by the run ID.
+CREATE TABLE IF NOT EXISTS scrape_runs (
+    run_id         INTEGER PRIMARY KEY,
+    scrape_started INTEGER NOT NULL
+);
+
+-- What happened to each extracted path during a scrape, to spot changes to the Play Store
+CREATE TABLE IF NOT EXISTS extraction_health (
+    run_id         INTEGER NOT NULL REFERENCES scrape_runs(run_id),
+    field          TEXT NOT NULL,
+    path           TEXT NOT NULL,
+    observed       INTEGER NOT NULL,
+    missing        INTEGER NOT NULL,
+    null_values    INTEGER NOT NULL,
+    wrong_type     INTEGER NOT NULL,
+    failures       INTEGER NOT NULL,
+    drifted        INTEGER NOT NULL CHECK (drifted IN (0, 1)),
+    PRIMARY KEY (run_id, field, path)
+);
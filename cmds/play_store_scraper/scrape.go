@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -41,6 +43,31 @@ type ScrapeConfig struct {
 	Language                    string
 	Country                     string
 	AdditionalCountriesForPrice []string
+
+	// Maximum number of requests in one batchexecute HTTP request
+	MaxBatchSize int
+
+	// Address of the Play Store, e.g. a fake one for testing
+	BaseUrl string
+
+	// Keeps track of changes to the layout of Google's responses
+	Monitor *playstore.Monitor
+}
+
+// Number of apps to scrape together so that the details, similar apps and data safety
+// requests for all of them fit in a single batchexecute HTTP request.
+func (config *ScrapeConfig) appsPerBatch() int {
+	const requestsPerApp = 3
+
+	maxBatchSize := config.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = playstore.DefaultMaxBatchSize
+	}
+
+	if maxBatchSize < requestsPerApp {
+		return 1
+	}
+	return maxBatchSize / requestsPerApp
 }
 
 func Scrape(ctx context.Context, db *sql.DB, numScrapers int) error {
@@ -54,6 +81,28 @@ func Scrape(ctx context.Context, db *sql.DB, numScrapers int) error {
 	retryableClient.HTTPClient.Transport.(*http.Transport).MaxIdleConnsPerHost = 100
 	defer retryableClient.HTTPClient.CloseIdleConnections()
 	client := retryableClient.StandardClient()
+	playstore.BaseUrl = scrapeConfig.BaseUrl
+
+	// Keep an eye on the responses for the whole run, so that we notice if Google changes
+	// something before all the apps have been scraped
+	started := time.Now()
+	monitor := playstore.NewMonitor()
+	monitor.OnDrift = func(stats playstore.PathStats) {
+		log.Printf("%s (%s) is suddenly null for %.0f%% of apps, up from %.0f%%: has the Play Store changed?",
+			stats.Field, stats.Path, 100*stats.WindowNullRate, 100*stats.BaselineNullRate)
+	}
+	config := scrapeConfig
+	config.Monitor = monitor
+
+	defer func() {
+		stats := monitor.Stats()
+		printHealthReport(os.Stderr, stats)
+
+		// The scrape may have been cancelled, but the report should still be saved
+		if err := insertHealthReport(context.Background(), db, started, stats); err != nil {
+			log.Printf("Could not save the extraction health report: %v", err)
+		}
+	}()
 
 	total, remaining, err := dbStatistics(ctx, db)
 	if err != nil {
@@ -139,14 +188,14 @@ func Scrape(ctx context.Context, db *sql.DB, numScrapers int) error {
 			return Writer(ctx, db, scrapedAppOut, notFoundAppOut)
 		})
 
-		toScrape := make(chan string, numScrapers)
+		toScrape := make(chan []string, numScrapers)
 
 		errgrp.Go(func() error {
-			for _, appId := range appIds {
+			for _, chunk := range chunks(appIds, config.appsPerBatch()) {
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
-				case toScrape <- appId:
+				case toScrape <- chunk:
 				}
 			}
 			close(toScrape)
@@ -159,47 +208,19 @@ func Scrape(ctx context.Context, db *sql.DB, numScrapers int) error {
 			// Spawn a number of worker goroutines
 			for i := 0; i < numScrapers; i++ {
 				errgrp.Go(func() error {
-				MainLoop:
 					for {
 						select {
 						case <-ctx.Done():
 							return ctx.Err()
-						case appId, ok := <-toScrape:
+						case appIds, ok := <-toScrape:
 							if !ok {
 								return nil
 							}
 
-							if err := ScrapeApp(ctx, client, scrapedAppIn, notFoundAppIn, scrapeConfig, appId); err != nil {
-								if errors.Is(err, context.Canceled) {
+							if err := ScrapeApps(ctx, client, scrapedAppIn, notFoundAppIn, config, appIds); err != nil {
+								if !ignorableError(err) {
 									return err
 								}
-
-								// Is this a fatal error or shall we ignore it?
-								var errNetwork net.Error
-								if errors.As(err, &errNetwork) {
-									log.Print("Network error: ", errNetwork)
-									continue MainLoop
-								}
-
-								if errors.Is(err, playstore.ErrRateLimited) {
-									log.Print(err)
-									continue MainLoop
-								}
-
-								var errExtractDetails *playstore.DetailsExtractError
-								if errors.As(err, &errExtractDetails) {
-									fmt.Println(errExtractDetails.Payload)
-									log.Print(errExtractDetails)
-									continue MainLoop
-								}
-
-								var errExtractSimilar *playstore.SimilarAppsExtractError
-								if errors.As(err, &errExtractSimilar) {
-									log.Print(errExtractSimilar)
-									continue MainLoop
-								}
-
-								return err
 							}
 						}
 					}
@@ -279,48 +300,177 @@ func appsToScrape(ctx context.Context, db *sql.DB, n int) ([]string, error) {
 	return appIds, nil
 }
 
-func ScrapeApp(ctx context.Context, client *http.Client, scrapedC chan<- ScrapedApp, notFoundC chan<- string, config ScrapeConfig, appId string) error {
+// Is this a fatal error or shall we log it and carry on?
+func ignorableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var errNetwork net.Error
+	if errors.As(err, &errNetwork) {
+		log.Print("Network error: ", errNetwork)
+		return true
+	}
+
+	if errors.Is(err, playstore.ErrRateLimited) {
+		log.Print(err)
+		return true
+	}
+
+	// Google rejected the request for this app, but the others may be fine
+	var errBatch *playstore.BatchError
+	if errors.As(err, &errBatch) {
+		log.Print(errBatch)
+		return true
+	}
+
+	// Google did not answer the request for this app, or rejected the batch it was in
+	var errRejected *playstore.BatchRejectedError
+	if errors.Is(err, playstore.ErrNoEnvelope) || errors.As(err, &errRejected) {
+		log.Print(err)
+		return true
+	}
+
+	// The paths that failed are counted by the monitor and reported at the end
+	var errExtract *playstore.ExtractError
+	if errors.As(err, &errExtract) {
+		log.Print(errExtract)
+		return true
+	}
+
+	return false
+}
+
+// Scrape several apps at once. The requests for all the apps are batched together, so
+// that there are as few HTTP requests as possible.
+func ScrapeApps(ctx context.Context, client *http.Client, scrapedC chan<- ScrapedApp, notFoundC chan<- string, config ScrapeConfig, appIds []string) error {
+	type results struct {
+		details    *playstore.Result[*playstore.Details]
+		similar    *playstore.Result[[]playstore.SimilarApp]
+		dataSafety *playstore.Result[*playstore.DataSafety]
+	}
+
 	// Batch requests for details, similar apps and data safety
-	requesters := []playstore.BatchRequester{
-		playstore.NewDetailsBatchRequester(appId),
-		playstore.NewSimilarBatchRequester(appId),
-		playstore.NewDataSafetyRequester(appId),
+	batch := &playstore.Batch{MaxSize: config.MaxBatchSize, Monitor: config.Monitor}
+	appResults := make([]results, 0, len(appIds))
+	for _, appId := range appIds {
+		appResults = append(appResults, results{
+			details:    playstore.Add(batch, playstore.NewDetailsBatchRequester(appId)),
+			similar:    playstore.Add(batch, playstore.NewSimilarBatchRequester(appId)),
+			dataSafety: playstore.Add(batch, playstore.NewDataSafetyRequester(appId)),
+		})
+	}
+
+	if err := batch.Send(ctx, client, config.Country, config.Language); err != nil {
+		var errRejected *playstore.BatchRejectedError
+		if !errors.As(err, &errRejected) {
+			return err
+		}
+		if len(appIds) == 1 {
+			return fmt.Errorf("%s: %w", appIds[0], err)
+		}
+		// The apps in the HTTP requests that were not rejected are scraped as usual
+		log.Printf("scraping the apps of %d rejected requests one at a time: %v", len(errRejected.Requests), err)
+	}
+
+	scrapedApps := make([]*ScrapedApp, 0, len(appIds))
+
+	// Google rejects a whole HTTP request if it does not like one of the requests in it,
+	// without saying which, so the apps in it are scraped again one at a time to only lose
+	// the bad one
+	var rejected []string
+
+	for i, appId := range appIds {
+		detailsResult := appResults[i].details
+		similarResult := appResults[i].similar
+		dataSafetyResult := appResults[i].dataSafety
+
+		var errRejected *playstore.BatchRejectedError
+		if errors.As(detailsResult.Err, &errRejected) {
+			rejected = append(rejected, appId)
+			continue
+		}
+
+		if errors.Is(detailsResult.Err, playstore.ErrAppNotFound) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case notFoundC <- appId:
+				continue
+			}
+		}
+		if detailsResult.Err != nil {
+			// One bad app should not stop the rest of the batch from being scraped
+			if ignorableError(fmt.Errorf("%s: %w", appId, detailsResult.Err)) {
+				continue
+			}
+			return detailsResult.Err
+		}
+
+		// The details are the important part, so keep them even if the similar apps or
+		// data safety could not be scraped.
+		similar := similarResult.Value
+		if similarResult.Err != nil {
+			log.Printf("%s: similar apps: %v", appId, similarResult.Err)
+		}
+
+		dataSafety := dataSafetyResult.Value
+		if dataSafetyResult.Err != nil {
+			log.Printf("%s: data safety: %v", appId, dataSafetyResult.Err)
+		}
+
+		scrapedApps = append(scrapedApps, &ScrapedApp{
+			AppId:       appId,
+			Country:     config.Country,
+			Language:    config.Language,
+			Details:     *detailsResult.Value,
+			SimilarApps: similar,
+			DataSafety:  dataSafety,
+		})
+	}
+
+	if err := scrapePrices(ctx, client, config, scrapedApps); err != nil {
+		return err
 	}
 
-	responses, err := playstore.SendBatchedRequests(ctx, client, config.Country, config.Language, requesters)
-	if errors.Is(err, playstore.ErrAppNotFound) {
+	for _, scrapedApp := range scrapedApps {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case notFoundC <- appId:
-			return nil
+		case scrapedC <- *scrapedApp:
 		}
 	}
-	if err != nil {
-		return err
-	}
 
-	details := responses[0].(*playstore.Details)
-	similar := responses[1].([]playstore.SimilarApp)
-	var dataSafety *playstore.DataSafety
-	if responses[2] != nil {
-		dataSafety = responses[2].(*playstore.DataSafety)
+	for _, appId := range rejected {
+		if err := ScrapeApps(ctx, client, scrapedC, notFoundC, config, []string{appId}); err != nil && !ignorableError(err) {
+			return err
+		}
 	}
 
-	var prices []PriceInfo
+	return nil
+}
+
+// Check if the apps are free or paid. If an app is paid (or there is a sale), then
+// scrape price data for additional countries. The requests for each country are batched.
+func scrapePrices(ctx context.Context, client *http.Client, config ScrapeConfig, scrapedApps []*ScrapedApp) error {
+	var paidApps []*ScrapedApp
 
-	// Now check if the app is free or paid. If the app is paid (or there is a sale), then scrape price data for additional countries.
-	if details.Price > 0 || details.OriginalPrice.ValueOrZero() > 0 {
-		prices = make([]PriceInfo, 1+len(config.AdditionalCountriesForPrice))
+	for _, scrapedApp := range scrapedApps {
+		details := &scrapedApp.Details
+		if !(details.Price > 0 || details.OriginalPrice.ValueOrZero() > 0) {
+			continue
+		}
 
 		// Some apps don't have a valid currency but I think this is only free apps.
 		// Error if otherwise
 		if details.Available && !details.Currency.Valid {
-			return fmt.Errorf("paid app does not have currency: %s", appId)
+			return fmt.Errorf("paid app does not have currency: %s", scrapedApp.AppId)
 		}
 
+		scrapedApp.prices = make([]PriceInfo, 1+len(config.AdditionalCountriesForPrice))
+
 		// Add price information for the primary country
-		prices[0] = PriceInfo{
+		scrapedApp.prices[0] = PriceInfo{
 			Country:       config.Country,
 			Available:     details.Available,
 			Currency:      details.Currency.String,
@@ -328,64 +478,120 @@ func ScrapeApp(ctx context.Context, client *http.Client, scrapedC chan<- Scraped
 			OriginalPrice: details.OriginalPrice,
 		}
 
-		// Then scrape price information for the additional countries
-		errgrp, scrapeCtx := errgroup.WithContext(ctx)
-		for i, country := range config.AdditionalCountriesForPrice {
-			i, country := i, country
-			errgrp.Go(func() error {
-				details, err := playstore.ScrapeDetails(scrapeCtx, client, appId, country, config.Language)
+		paidApps = append(paidApps, scrapedApp)
+	}
+
+	if len(paidApps) == 0 {
+		return nil
+	}
+
+	// Then scrape price information for the additional countries
+	errgrp, scrapeCtx := errgroup.WithContext(ctx)
+	for i, country := range config.AdditionalCountriesForPrice {
+		i, country := i, country
+		errgrp.Go(func() error {
+			batch := &playstore.Batch{MaxSize: config.MaxBatchSize}
+			results := make([]*playstore.Result[*playstore.Details], 0, len(paidApps))
+			for _, paidApp := range paidApps {
+				results = append(results, playstore.Add(batch, playstore.NewDetailsBatchRequester(paidApp.AppId)))
+			}
+
+			// Losing the prices in one country is better than losing the whole chunk of apps.
+			// The prices that could not be scraped are left out, like those of apps that are
+			// not available in the country. If Google only rejected some of the requests, only
+			// the apps in them lose their price.
+			var errRejected *playstore.BatchRejectedError
+			if err := batch.Send(scrapeCtx, client, country, config.Language); err != nil && !errors.As(err, &errRejected) {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				log.Printf("prices in %s: %v", country, err)
+				for _, paidApp := range paidApps {
+					paidApp.prices[i+1] = PriceInfo{
+						Country: country,
+					}
+				}
+				return nil
+			}
+
+			for j, paidApp := range paidApps {
+				details, err := results[j].Value, results[j].Err
 
 				// Sometimes when looking at other countries, the Play Store can report apps as not found
 				// (404 error) rather than unavailable.
-				if err == playstore.ErrAppNotFound {
-					prices[i+1] = PriceInfo{
+				if errors.Is(err, playstore.ErrAppNotFound) {
+					paidApp.prices[i+1] = PriceInfo{
 						Country: country,
 					}
-					return nil
-				}
-				if err != nil {
-					return err
+					continue
 				}
 
 				// Some apps don't have a valid currency but I think this is only free apps.
-				// Return Error if otherwise. Only check if the currency is valid or not if
-				// the app was found.
-				if details.Available && !details.Currency.Valid {
-					return fmt.Errorf("paid app does not have currency: %s", appId)
+				// Only check if the currency is valid or not if the app was found.
+				if err == nil && details.Available && !details.Currency.Valid {
+					err = fmt.Errorf("paid app does not have currency: %s", paidApp.AppId)
 				}
 
-				prices[i+1] = PriceInfo{
+				// The price of one app is not worth losing the others for
+				if err != nil {
+					log.Printf("%s: price in %s: %v", paidApp.AppId, country, err)
+					paidApp.prices[i+1] = PriceInfo{
+						Country: country,
+					}
+					continue
+				}
+
+				paidApp.prices[i+1] = PriceInfo{
 					Country:       country,
 					Available:     details.Available,
 					Currency:      details.Currency.String,
 					Price:         details.Price,
 					OriginalPrice: details.OriginalPrice,
 				}
+			}
 
-				return nil
-			})
-		}
+			return nil
+		})
+	}
 
-		if err := errgrp.Wait(); err != nil {
-			return err
-		}
+	return errgrp.Wait()
+}
+
+func chunks(xs []string, chunkSize int) [][]string {
+	if len(xs) == 0 {
+		return nil
 	}
+	numChunks := (len(xs) + chunkSize - 1) / chunkSize
+	divided := make([][]string, 0, numChunks)
+
+	for i := 0; i < len(xs); i += chunkSize {
+		end := i + chunkSize
+
+		if end > len(xs) {
+			end = len(xs)
+		}
 
-	scrapedApp := ScrapedApp{
-		AppId:       appId,
-		Country:     config.Country,
-		Language:    config.Language,
-		Details:     *details,
-		SimilarApps: similar,
-		DataSafety:  dataSafety,
-		prices:      prices,
+		divided = append(divided, xs[i:end])
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case scrapedC <- scrapedApp:
+	return divided
+}
+
+// Print a table of what happened to every extracted path during the scrape
+func printHealthReport(w io.Writer, stats []playstore.PathStats) {
+	if len(stats) == 0 {
+		return
 	}
 
-	return nil
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Field\tPath\tObserved\tMissing\tNull\tWrong type\tFailures\tNull rate\tDrifted\t")
+	for _, s := range stats {
+		drifted := ""
+		if s.Drifted {
+			drifted = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t\n",
+			s.Field, s.Path, s.Observed, s.Missing, s.Null, s.WrongType, s.Failures, 100*s.NullRate(), drifted)
+	}
+	tw.Flush()
 }
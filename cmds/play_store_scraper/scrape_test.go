@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"cmds/internal/database"
+	"cmds/internal/dbtest"
+	"cmds/internal/fakestore"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/playstore"
+)
+
+func fakeDetails(title string) playstore.Details {
+	return playstore.Details{
+		Title:           title,
+		DescriptionHTML: "An app called <b>" + title + "</b>",
+		Installs:        null.StringFrom("1,000+"),
+		MinInstalls:     null.IntFrom(1000),
+		Genre:           "TOOLS",
+		Developer:       "Example Developer",
+		DeveloperId:     "5509190841173705883",
+		Updated:         time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC),
+		Available:       true,
+		Permissions: []playstore.Permission{
+			{Group: "Location", Permission: "precise location"},
+			{Group: "Other", Permission: "full network access"},
+		},
+	}
+}
+
+func TestScrape(t *testing.T) {
+	store := fakestore.NewPlayStore()
+	defer store.Close()
+
+	baseUrl := scrapeConfig.BaseUrl
+	scrapeConfig.BaseUrl = store.URL
+	defer func() { scrapeConfig.BaseUrl = baseUrl }()
+
+	paid := fakeDetails("Paid App")
+	paid.Price = 1.99
+	paid.Currency = null.StringFrom("USD")
+
+	store.AddApp(fakestore.PlayApp{
+		AppId:   "com.example.free",
+		Details: fakeDetails("Free App"),
+		Similar: []playstore.SimilarApp{{AppId: "com.example.similar", Title: "Similar App", Developer: "Someone Else"}},
+	})
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.paid", Details: paid})
+
+	// Google does not like us at first
+	store.Fail("/_/PlayStoreUi/data/batchexecute", fakestore.FaultCaptcha, 1)
+	store.Fail("/_/PlayStoreUi/data/batchexecute", fakestore.FaultMalformed, 1)
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []string{"com.example.free", "com.example.paid", "com.example.missing"} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Scrape(context.Background(), db, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The similar app has been discovered and scraped as well, but it does not exist
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM not_found_apps"))
+
+	// Prices for the paid app in every country
+	assert.Equal(t, 1+len(scrapeConfig.AdditionalCountriesForPrice), dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.paid' AND price = 1.99"))
+
+	// Every extracted field is in the health report, and nothing went wrong
+	assert.Positive(t, dbtest.Count(t, db, "SELECT COUNT(*) FROM extraction_health WHERE field = 'Details.Installs'"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM extraction_health WHERE drifted"))
+}
+
+func TestScrapePriceError(t *testing.T) {
+	store := fakestore.NewPlayStore()
+	defer store.Close()
+
+	baseUrl := scrapeConfig.BaseUrl
+	scrapeConfig.BaseUrl = store.URL
+	defer func() { scrapeConfig.BaseUrl = baseUrl }()
+
+	paid := fakeDetails("Paid App")
+	paid.Price = 1.99
+	paid.Currency = null.StringFrom("USD")
+
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.paid", Details: paid})
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.broken", Details: paid, MalformedIn: []string{"gb"}})
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []string{"com.example.paid", "com.example.broken"} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Scrape(context.Background(), db, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the price that could not be scraped is missing
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+	assert.Equal(t, 1+len(scrapeConfig.AdditionalCountriesForPrice), dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.paid' AND price = 1.99"))
+	assert.Equal(t, len(scrapeConfig.AdditionalCountriesForPrice), dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.broken' AND price = 1.99"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.broken' AND country = 'gb'"))
+}
+
+func TestScrapePriceCountryError(t *testing.T) {
+	store := fakestore.NewPlayStore()
+	defer store.Close()
+
+	baseUrl := scrapeConfig.BaseUrl
+	scrapeConfig.BaseUrl = store.URL
+	defer func() { scrapeConfig.BaseUrl = baseUrl }()
+
+	paid := fakeDetails("Paid App")
+	paid.Price = 1.99
+	paid.Currency = null.StringFrom("USD")
+
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.paid", Details: paid})
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.free", Details: fakeDetails("Free App")})
+
+	// Every request in one of the additional countries fails
+	store.FailQuery("/_/PlayStoreUi/data/batchexecute", "gl", "de", fakestore.FaultCaptcha, -1)
+
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+	for _, appId := range []string{"com.example.paid", "com.example.free"} {
+		if _, err := db.Exec("INSERT INTO apps (app_id) VALUES (?)", appId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Scrape(context.Background(), db, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The apps are kept, and only the price in the failed country is missing
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scraped_apps"))
+	assert.Equal(t, len(scrapeConfig.AdditionalCountriesForPrice), dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.paid' AND price = 1.99"))
+	assert.Equal(t, 0, dbtest.Count(t, db, "SELECT COUNT(*) FROM prices WHERE app_id = 'com.example.paid' AND country = 'de'"))
+}
+
+func TestScrapeAppsRejectedBatch(t *testing.T) {
+	store := fakestore.NewPlayStore()
+	defer store.Close()
+
+	client := http.DefaultClient
+	defer func(baseUrl string) { playstore.BaseUrl = baseUrl }(playstore.BaseUrl)
+	playstore.BaseUrl = store.URL
+
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.free", Details: fakeDetails("Free App")})
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.rejected", Details: fakeDetails("Rejected App"), RejectedIn: []string{"us"}})
+	store.AddApp(fakestore.PlayApp{AppId: "com.example.unanswered", Details: fakeDetails("Unanswered App"), UnansweredIn: []string{"us"}})
+
+	config := scrapeConfig
+	config.Country = "us"
+	config.AdditionalCountriesForPrice = nil
+
+	scrapedC := make(chan ScrapedApp, 3)
+	notFoundC := make(chan string, 3)
+	appIds := []string{"com.example.free", "com.example.rejected", "com.example.unanswered"}
+	if err := ScrapeApps(context.Background(), client, scrapedC, notFoundC, config, appIds); err != nil {
+		t.Fatal(err)
+	}
+	close(scrapedC)
+	close(notFoundC)
+
+	// Only the apps that Google did not answer for are skipped
+	var scraped []string
+	for scrapedApp := range scrapedC {
+		scraped = append(scraped, scrapedApp.AppId)
+	}
+	assert.Equal(t, []string{"com.example.free"}, scraped)
+	assert.Empty(t, notFoundC)
+}
+
+func TestHealthReportSameSecond(t *testing.T) {
+	db := dbtest.Open(t, database.DatabaseAppStore, DatabaseVersion, databaseSchema)
+
+	started := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+	stats := []playstore.PathStats{{Field: "Details.Title", Path: "1.2.0.0", Observed: 10}}
+
+	// Two scrapes that start in the same second both keep their report
+	for i := 0; i < 2; i++ {
+		if err := insertHealthReport(context.Background(), db, started, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(*) FROM scrape_runs WHERE scrape_started = ?", started.Unix()))
+	assert.Equal(t, 2, dbtest.Count(t, db, "SELECT COUNT(DISTINCT run_id) FROM extraction_health WHERE field = 'Details.Title'"))
+}
@@ -0,0 +1,330 @@
+// Package httprecord replays HTTP responses from fixture files, so that tests can run
+// offline and do not break whenever a listing changes, and records the fixtures from the
+// live services.
+//
+// Tests get a client with Client(t). By default, the responses are replayed from
+// testdata/fixtures/<test name>.json, which holds responses recorded from the live
+// services, or if there is no recording, from testdata/synthetic/<test name>.json. The
+// synthetic fixtures were written by hand to follow the layout of the live responses.
+// They show that the scrapers handle that layout, but not that it is still what the
+// stores send. The test fails if there is no fixture of either kind.
+//
+// To record the fixtures against the live services, pass -record to the tests, e.g.
+//
+//	go test ./playstore ./appstore -record
+//
+// The recordings are written to testdata/fixtures and take the place of the synthetic
+// fixtures. Only the packages that import httprecord know the flag, so go test ./...
+// -record fails in the others. Setting RECORD_FIXTURES does the same as the flag and
+// works for go test ./... too.
+package httprecord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Set to record the fixtures rather than replay them
+var record = flag.Bool("record", false, "record the HTTP fixtures from the live services")
+
+// Does the same as -record
+const recordEnv = "RECORD_FIXTURES"
+
+// Query parameters that change from session to session, so are ignored when matching
+// requests to the recorded responses
+var volatileParams = []string{"f.sid", "bl", "_reqid"}
+
+// Only these response headers are kept, so that cookies etc. do not end up in the fixtures
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+var ErrNoFixture = errors.New("no fixture")
+
+type Request struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// A http.RoundTripper that either records the responses of the live services or replays
+// previously recorded responses
+type Recorder struct {
+	path      string
+	recording bool
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// Create a recorder for the fixture file at path. When replaying, ErrNoFixture is
+// returned if the file does not exist.
+func New(path string, recording bool) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		recording: recording,
+		transport: http.DefaultTransport,
+	}
+
+	if recording {
+		return r, nil
+	}
+
+	interactions, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.interactions = interactions
+	r.used = make([]bool, len(interactions))
+
+	return r, nil
+}
+
+// Load the interactions in the fixture file at path, e.g. to benchmark parsing the
+// recorded responses. ErrNoFixture is returned if the file does not exist.
+func Load(path string) ([]Interaction, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return f.Interactions, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	request := Request{Method: req.Method, Url: req.URL.String(), Body: string(body)}
+
+	if r.recording {
+		return r.recordRoundTrip(req, request)
+	}
+
+	return r.replayRoundTrip(req, request)
+}
+
+func (r *Recorder) recordRoundTrip(req *http.Request, request Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := make(http.Header)
+	for _, key := range keptHeaders {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			header[key] = values
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = append(r.interactions, Interaction{
+		Request:  request,
+		Response: Response{StatusCode: resp.StatusCode, Header: header, Body: string(body)},
+	})
+	r.used = append(r.used, true)
+
+	return resp, nil
+}
+
+func (r *Recorder) replayRoundTrip(req *http.Request, request Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Prefer responses that have not been replayed yet, so that repeated requests (e.g.
+	// following pages) get their responses in the order they were recorded
+	key := requestKey(request)
+	match := -1
+	for i, interaction := range r.interactions {
+		if requestKey(interaction.Request) != key {
+			continue
+		}
+		if !r.used[i] {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+
+	if match == -1 {
+		return nil, fmt.Errorf("%s: no recorded response for %s %s (re-record with -record)", r.path, request.Method, request.Url)
+	}
+	r.used[match] = true
+
+	response := r.interactions[match].Response
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Write the recorded responses to the fixture file. This does nothing when replaying.
+func (r *Recorder) Save() error {
+	if !r.recording {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	raw, err := json.MarshalIndent(fixture{Interactions: r.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, raw, 0o644)
+}
+
+func requestKey(request Request) string {
+	u, err := url.Parse(request.Url)
+	if err != nil {
+		return request.Method + " " + request.Url + " " + request.Body
+	}
+
+	// Some of the Play Store URLs have semicolons in the query, which url.ParseQuery
+	// rejects, so only filter queries that can be parsed
+	if query, err := url.ParseQuery(u.RawQuery); err == nil {
+		for _, param := range volatileParams {
+			query.Del(param)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return request.Method + " " + u.String() + " " + request.Body
+}
+
+// The directories in testdata of the recorded and the hand-written fixtures
+const (
+	recordedDir  = "fixtures"
+	syntheticDir = "synthetic"
+)
+
+func fixtureName(t testing.TB) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+}
+
+// The path of the recorded fixture file for a test
+func FixturePath(t testing.TB) string {
+	return RecordedPath(fixtureName(t))
+}
+
+// The path of the recorded fixture file for the test with the given name, which may not
+// have been recorded yet
+func RecordedPath(name string) string {
+	return filepath.Join("testdata", recordedDir, name+".json")
+}
+
+// The path of the fixture file to replay for the test with the given name: the recorded
+// fixture if there is one, otherwise the synthetic one
+func ReplayPath(name string) string {
+	recorded := RecordedPath(name)
+	if _, err := os.Stat(recorded); err == nil {
+		return recorded
+	}
+
+	return filepath.Join("testdata", syntheticDir, name+".json")
+}
+
+// A client for the test that replays the test's fixture, or records it if the tests are
+// run with -record or RECORD_FIXTURES is set. The test fails if there is no fixture to
+// replay.
+func Client(t testing.TB) *http.Client {
+	t.Helper()
+
+	recording := *record || os.Getenv(recordEnv) != ""
+	path := FixturePath(t)
+	if !recording {
+		path = ReplayPath(fixtureName(t))
+	}
+
+	recorder, err := New(path, recording)
+	if errors.Is(err, ErrNoFixture) {
+		t.Fatalf("%v: run the test with -record to record it", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// A failed recording would take the place of a good fixture
+		if recording && t.Failed() {
+			t.Logf("not saving the fixture of a failed test to %s", path)
+			return
+		}
+		if err := recorder.Save(); err != nil {
+			t.Errorf("saving fixture: %v", err)
+		}
+	})
+
+	return &http.Client{Transport: recorder}
+}
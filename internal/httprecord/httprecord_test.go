@@ -0,0 +1,119 @@
+package httprecord
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var numRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&numRequests, 1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Set-Cookie", "secret=1")
+		fmt.Fprintf(w, "response %d", n)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	recorder, err := New(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: recorder}
+
+	get(t, client, server.URL+"/page?f.sid=1&_reqid=1000&hl=en")
+	get(t, client, server.URL+"/page?f.sid=1&_reqid=101000&hl=en")
+	get(t, client, server.URL+"/missing")
+
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay without the server
+	server.Close()
+
+	replayer, err := New(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replayer}
+
+	// The session parameters are different, but the responses come back in order
+	status, body := get(t, client, server.URL+"/page?f.sid=2&_reqid=2000&hl=en")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "response 1", body)
+
+	status, body = get(t, client, server.URL+"/page?f.sid=2&_reqid=102000&hl=en")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "response 2", body)
+
+	status, _ = get(t, client, server.URL+"/missing")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Only the interesting headers are recorded
+	assert.Empty(t, replayer.interactions[0].Response.Header.Values("Set-Cookie"))
+
+	// Other parameters still have to match
+	_, err = client.Get(server.URL + "/page?f.sid=2&_reqid=2000&hl=de")
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "no recorded response"))
+	}
+}
+
+func TestNoFixture(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "fixture.json"), false)
+	assert.True(t, errors.Is(err, ErrNoFixture))
+}
+
+func TestReplayPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// Without a recording, the synthetic fixture is replayed
+	assert.Equal(t, filepath.Join("testdata", "synthetic", "TestApp.json"), ReplayPath("TestApp"))
+
+	// A recording takes the place of the synthetic fixture
+	if err := os.MkdirAll(filepath.Join("testdata", "fixtures"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("testdata", "fixtures", "TestApp.json"), []byte(`{"interactions":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, filepath.Join("testdata", "fixtures", "TestApp.json"), ReplayPath("TestApp"))
+}
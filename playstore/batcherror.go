@@ -0,0 +1,101 @@
+package playstore
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Status codes that Google's RPCs return in error envelopes. They are the same as the
+// gRPC status codes.
+type StatusCode int
+
+const (
+	StatusInvalidArgument    StatusCode = 3
+	StatusNotFound           StatusCode = 5
+	StatusPermissionDenied   StatusCode = 7
+	StatusResourceExhausted  StatusCode = 8
+	StatusFailedPrecondition StatusCode = 9
+	StatusInternal           StatusCode = 13
+	StatusUnavailable        StatusCode = 14
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusInvalidArgument:
+		return "invalid argument"
+	case StatusNotFound:
+		return "not found"
+	case StatusPermissionDenied:
+		return "permission denied"
+	case StatusResourceExhausted:
+		return "resource exhausted"
+	case StatusFailedPrecondition:
+		return "failed precondition"
+	case StatusInternal:
+		return "internal error"
+	case StatusUnavailable:
+		return "unavailable"
+	default:
+		return fmt.Sprintf("status %d", int(c))
+	}
+}
+
+// Google rejected a request in the batch. Number is the position of the request in the
+// batch, or -1 if Google did not say which request it was, in which case every request
+// for the RPC that was not answered gets the error.
+type BatchError struct {
+	RpcId  string
+	Code   StatusCode
+	Number int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batchexecute %s (request %d) rejected: %s", e.RpcId, e.Number, e.Code)
+}
+
+// Running out of quota is just another way of being rate limited.
+func (e *BatchError) Is(target error) bool {
+	return target == ErrRateLimited && e.Code == StatusResourceExhausted
+}
+
+// Schema changes usually show up as Google rejecting the payload of the request.
+func (e *BatchError) SchemaChanged() bool {
+	return e.Code == StatusInvalidArgument || e.Code == StatusFailedPrecondition
+}
+
+// Google rejected a whole batchexecute HTTP request, e.g. because f.req could not be
+// parsed. Unlike the status codes of the requests, Status is an HTTP status code, e.g. 400.
+//
+// The er envelope does not say which request Google objected to, so Requests has the
+// positions in the Batch of all the requests that were sent in the rejected HTTP request.
+// To find the bad request, only these requests need to be sent again, one at a time.
+type BatchRejectedError struct {
+	Status   int
+	Requests []int
+}
+
+func (e *BatchRejectedError) Error() string {
+	return fmt.Sprintf("batchexecute rejected %d requests: HTTP status %d", len(e.Requests), e.Status)
+}
+
+// The status is either a bare number or an array with the number as its first element,
+// e.g. [5].
+func parseStatusCode(raw json.RawMessage) (StatusCode, bool) {
+	if string(raw) == "null" {
+		return 0, false
+	}
+
+	var code int
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return StatusCode(code), true
+	}
+
+	var status []json.RawMessage
+	if err := json.Unmarshal(raw, &status); err == nil && len(status) > 0 {
+		if err := json.Unmarshal(status[0], &code); err == nil {
+			return StatusCode(code), true
+		}
+	}
+
+	return 0, false
+}
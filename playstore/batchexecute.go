@@ -17,15 +17,34 @@ import (
 
 var ErrRateLimited error = errors.New("google detected unusual traffic")
 
+// Google did not send back a response for a request in the batch
+var ErrNoEnvelope error = errors.New("no envelope")
+
 type envelope struct {
 	RpcId   string
 	Payload string
 	Number  int
+
+	// Set if Google rejected the request: a *BatchError, or a *BatchRejectedError if the
+	// whole HTTP request was rejected
+	Err error
 }
 
-type BatchRequester interface {
+type BatchRequester[T any] interface {
 	BatchRequest() batchRequest
-	ParseEnvelope(string) (interface{}, error)
+	ParseEnvelope(string) (T, error)
+}
+
+// Requesters that can report the fields they extract to a Monitor
+type monitoredBatchRequester[T any] interface {
+	parseEnvelope(payload string, monitor *Monitor) (T, error)
+}
+
+// The result of one request in a batch. Each request succeeds or fails on its own, so
+// one bad response does not spoil the rest of the batch.
+type Result[T any] struct {
+	Value T
+	Err   error
 }
 
 type batchRequest struct {
@@ -34,7 +53,9 @@ type batchRequest struct {
 }
 
 // See https://kovatch.medium.com/deciphering-google-batchexecute-74991e4e446c for more
-// information about Google's RPC. We are interested in the wrb.fr response.
+// information about Google's RPC. We are interested in the wrb.fr responses, which have
+// either a payload or a status code if the request failed, and the er response, which
+// means that the whole batch failed.
 func respToEnvelopes(body []byte) ([]envelope, error) {
 	if bytes.HasPrefix(body, []byte("<!DOCTYPE html")) {
 		// Google is not happy with us :(
@@ -42,7 +63,6 @@ func respToEnvelopes(body []byte) ([]envelope, error) {
 	}
 
 	if !bytes.HasPrefix(body, []byte(")]}'\n\n")) {
-		fmt.Println(string(body))
 		return nil, fmt.Errorf("invalid response")
 	}
 
@@ -55,51 +75,73 @@ func respToEnvelopes(body []byte) ([]envelope, error) {
 
 	var envelopes []envelope
 	for _, rawEnvelope := range wrapper {
-		if len(rawEnvelope) == 7 {
-			var header string
+		if len(rawEnvelope) == 0 {
+			continue
+		}
+
+		var header string
+		if err := json.Unmarshal(rawEnvelope[0], &header); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case header == "wrb.fr" && len(rawEnvelope) == 7:
 			var envelope envelope
 
-			if err := json.Unmarshal(rawEnvelope[0], &header); err != nil {
+			if err := json.Unmarshal(rawEnvelope[1], &envelope.RpcId); err != nil {
 				return nil, err
 			}
-			if header == "wrb.fr" {
-				if err := json.Unmarshal(rawEnvelope[1], &envelope.RpcId); err != nil {
-					return nil, err
-				}
 
-				if err := json.Unmarshal(rawEnvelope[2], &envelope.Payload); err != nil {
-					return nil, err
-				}
-				if envelope.Payload != "" && !gjson.Valid(envelope.Payload) {
-					return nil, fmt.Errorf("envelope has invalid JSON payload")
-				}
+			if err := json.Unmarshal(rawEnvelope[2], &envelope.Payload); err != nil {
+				return nil, err
+			}
+			if envelope.Payload != "" && !gjson.Valid(envelope.Payload) {
+				return nil, fmt.Errorf("envelope has invalid JSON payload")
+			}
 
-				var number string
-				if err := json.Unmarshal(rawEnvelope[6], &number); err != nil {
-					return nil, err
-				}
-				if n, err := strconv.Atoi(number); err != nil {
-					return nil, err
-				} else {
-					envelope.Number = n
+			// Failed requests sometimes have a non-numeric number such as "generic"
+			envelope.Number = -1
+			var number string
+			if err := json.Unmarshal(rawEnvelope[6], &number); err != nil {
+				return nil, err
+			}
+			if n, err := strconv.Atoi(number); err == nil {
+				envelope.Number = n
+			}
+
+			if code, ok := parseStatusCode(rawEnvelope[5]); ok && envelope.Payload == "" {
+				// Missing apps are reported without a payload, and sometimes with a
+				// not found status. Leave them for the requesters to deal with, so they
+				// can return their own not found errors.
+				if code != StatusNotFound {
+					envelope.Err = &BatchError{RpcId: envelope.RpcId, Code: code, Number: envelope.Number}
 				}
+			} else if envelope.Number == -1 {
+				return nil, fmt.Errorf("envelope has invalid number %s", number)
+			}
+
+			envelopes = append(envelopes, envelope)
 
-				envelopes = append(envelopes, envelope)
+		case header == "er" && len(rawEnvelope) > 5:
+			var status int
+			if err := json.Unmarshal(rawEnvelope[5], &status); err != nil {
+				return nil, err
 			}
+			return nil, &BatchRejectedError{Status: status}
 		}
 	}
 
 	return envelopes, nil
 }
 
-func sendRequests(ctx context.Context, client *http.Client, country string, language string, requesters []BatchRequester) ([]envelope, error) {
-	const batchExecuteUrl = "https://play.google.com/_/PlayStoreUi/data/batchexecute"
+func sendRequests(ctx context.Context, client *http.Client, country string, language string, requests []batchRequest) ([]envelope, error) {
+	batchExecuteUrl := BaseUrl + "/_/PlayStoreUi/data/batchexecute"
 
 	// Make the body of the request
-	rpcids := make([]string, 0, len(requesters))
-	fReq := make([][]*string, 0, len(requesters))
-	for i, requester := range requesters {
-		br := requester.BatchRequest()
+	rpcids := make([]string, 0, len(requests))
+	fReq := make([][]*string, 0, len(requests))
+	for i := range requests {
+		br := requests[i]
 		num := fmt.Sprintf("%d", i)
 		fReq = append(fReq, []*string{&br.RpcId, &br.Payload, nil, &num})
 		rpcids = append(rpcids, br.RpcId)
@@ -113,32 +155,57 @@ func sendRequests(ctx context.Context, client *http.Client, country string, lang
 	form := url.Values{}
 	form.Set("f.req", string(fReqJson))
 
-	req, err := http.NewRequestWithContext(ctx, "POST", batchExecuteUrl, strings.NewReader(form.Encode()))
-	if err != nil {
-		return nil, err
-	}
+	session := getSession(client)
 
-	// Build the URL
-	params := req.URL.Query()
-	params.Add("rpcids", strings.Join(rpcids, ","))
-	params.Add("f.sid", "-2272275650025625973")
-	params.Add("hl", language)
-	params.Add("gl", country)
-	params.Add("authuser", "")
-	params.Add("_reqid", "181072")
-	req.URL.RawQuery = params.Encode()
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		sessionParams, err := session.params(ctx, client)
+		if err != nil {
+			return nil, err
+		}
 
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+		req, err := http.NewRequestWithContext(ctx, "POST", batchExecuteUrl, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+		// Build the URL
+		params := req.URL.Query()
+		params.Add("rpcids", strings.Join(rpcids, ","))
+		for key, values := range sessionParams {
+			for _, value := range values {
+				params.Add(key, value)
+			}
+		}
+		params.Add("hl", language)
+		params.Add("gl", country)
+		params.Add("authuser", "")
+		req.URL.RawQuery = params.Encode()
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, ErrRateLimited
+		}
+
+		// The session has probably expired, so get a new one and try again
+		if resp.StatusCode == http.StatusBadRequest && attempt == 0 {
+			session.invalidate()
+			continue
+		}
+
+		break
 	}
 
 	envelopes, err := respToEnvelopes(body)
@@ -149,20 +216,166 @@ func sendRequests(ctx context.Context, client *http.Client, country string, lang
 	return envelopes, nil
 }
 
-func SendBatchedRequests(ctx context.Context, client *http.Client, country string, language string, requesters []BatchRequester) ([]interface{}, error) {
-	envelopes, err := sendRequests(ctx, client, country, language, requesters)
-	if err != nil {
-		return nil, err
+// The default maximum number of requests in a single batchexecute HTTP request
+const DefaultMaxBatchSize = 60
+
+// A batch of requests, possibly of different types and for different apps, that are
+// sent to Google together. Large batches are split into several HTTP requests of at
+// most MaxSize requests each (or DefaultMaxBatchSize if MaxSize is zero).
+type Batch struct {
+	MaxSize int
+
+	// Optional monitor for changes to the payloads
+	Monitor *Monitor
+
+	requests []batchRequest
+	results  []func(envelope *envelope)
+}
+
+// Add a request to the batch. The result is filled in when the batch is sent.
+func Add[T any](b *Batch, requester BatchRequester[T]) *Result[T] {
+	result := &Result[T]{Err: ErrNoEnvelope}
+
+	b.requests = append(b.requests, requester.BatchRequest())
+	b.results = append(b.results, func(envelope *envelope) {
+		switch {
+		case envelope == nil:
+			result.Err = ErrNoEnvelope
+		case envelope.Err != nil:
+			result.Err = envelope.Err
+		default:
+			if monitored, ok := requester.(monitoredBatchRequester[T]); ok && b.Monitor != nil {
+				result.Value, result.Err = monitored.parseEnvelope(envelope.Payload, b.Monitor)
+			} else {
+				result.Value, result.Err = requester.ParseEnvelope(envelope.Payload)
+			}
+		}
+	})
+
+	return result
+}
+
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+// Send the batch and fill in the results of the requests. Errors for individual requests
+// are in their results: requests that Google rejected get a *BatchError and requests that
+// Google did not respond to at all get ErrNoEnvelope.
+//
+// An error is returned if the batch failed, e.g. because of a network error, in which
+// case the results are not filled in. If Google rejected some of the HTTP requests of the
+// batch, the other HTTP requests are still sent and their results are filled in, the
+// requests in the rejected HTTP requests get a *BatchRejectedError, and a
+// *BatchRejectedError with all of them is returned.
+func (b *Batch) Send(ctx context.Context, client *http.Client, country string, language string) error {
+	maxSize := b.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBatchSize
 	}
 
-	output := make([]interface{}, len(requesters))
+	responses := make([]*envelope, len(b.requests))
+	var errRejected *BatchRejectedError
 
-	for _, envelope := range envelopes {
-		output[envelope.Number], err = requesters[envelope.Number].ParseEnvelope(envelope.Payload)
+	for start := 0; start < len(b.requests); start += maxSize {
+		end := start + maxSize
+		if end > len(b.requests) {
+			end = len(b.requests)
+		}
+
+		envelopes, err := sendRequests(ctx, client, country, language, b.requests[start:end])
+
+		var errChunk *BatchRejectedError
+		if errors.As(err, &errChunk) {
+			if errRejected == nil {
+				errRejected = &BatchRejectedError{Status: errChunk.Status}
+			}
+			for j := start; j < end; j++ {
+				errRejected.Requests = append(errRejected.Requests, j)
+			}
+
+			rejected := &envelope{Number: -1, Err: errRejected}
+			for j := start; j < end; j++ {
+				responses[j] = rejected
+			}
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		// The envelope number is the position of the request in this HTTP request. Envelopes
+		// without a number never have a payload, see respToEnvelopes.
+		for i := range envelopes {
+			envelope := &envelopes[i]
+			if envelope.Number == -1 {
+				continue
+			}
+			if envelope.Number < 0 || envelope.Number >= end-start {
+				return fmt.Errorf("envelope has invalid number %d", envelope.Number)
+			}
+			responses[start+envelope.Number] = envelope
 		}
+
+		// Errors and not found responses without a number apply to all the unanswered
+		// requests for the same RPC
+		for i := range envelopes {
+			envelope := &envelopes[i]
+			if envelope.Number != -1 {
+				continue
+			}
+			for j := start; j < end; j++ {
+				if responses[j] == nil && b.requests[j].RpcId == envelope.RpcId {
+					responses[j] = envelope
+				}
+			}
+		}
+	}
+
+	for i, result := range b.results {
+		result(responses[i])
+	}
+
+	if errRejected != nil {
+		return errRejected
+	}
+
+	return nil
+}
+
+// Send requests of the same type in a single batch. The results are in the same order
+// as the requesters. Like Batch.Send, the results are still returned with a
+// *BatchRejectedError if only some of the requests were rejected.
+func SendBatchedRequests[T any](ctx context.Context, client *http.Client, country string, language string, requesters []BatchRequester[T]) ([]Result[T], error) {
+	batch := &Batch{}
+	pending := make([]*Result[T], 0, len(requesters))
+	for _, requester := range requesters {
+		pending = append(pending, Add(batch, requester))
+	}
+
+	err := batch.Send(ctx, client, country, language)
+	var errRejected *BatchRejectedError
+	if err != nil && !errors.As(err, &errRejected) {
+		return nil, err
+	}
+
+	results := make([]Result[T], 0, len(pending))
+	for _, result := range pending {
+		results = append(results, *result)
+	}
+
+	return results, err
+}
+
+// Send a single request
+func sendRequest[T any](ctx context.Context, client *http.Client, country string, language string, requester BatchRequester[T]) (T, error) {
+	batch := &Batch{}
+	result := Add(batch, requester)
+
+	if err := batch.Send(ctx, client, country, language); err != nil {
+		var zero T
+		return zero, err
 	}
 
-	return output, nil
+	return result.Value, result.Err
 }
@@ -0,0 +1,284 @@
+package playstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// A client that always responds with the given envelopes, without touching the network
+func fakeBatchExecuteClient(t *testing.T, envelopes [][]interface{}) *http.Client {
+	body, err := json.Marshal(envelopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(")]}'\n\n" + string(body))),
+				Header:     make(http.Header),
+				Request:    req,
+			}, nil
+		}),
+	}
+}
+
+func TestBatchPartialFailure(t *testing.T) {
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		// Details that cannot be extracted
+		{"wrb.fr", "Ws7gDc", "[]", nil, nil, nil, "0"},
+		// No similar apps
+		{"wrb.fr", "ag2B9c", "[]", nil, nil, nil, "1"},
+		// Nothing for the data safety request
+	})
+
+	batch := &Batch{}
+	details := Add(batch, NewDetailsBatchRequester("com.example.app"))
+	similar := Add(batch, NewSimilarBatchRequester("com.example.app"))
+	dataSafety := Add(batch, NewDataSafetyRequester("com.example.app"))
+
+	err := batch.Send(context.Background(), client, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errExtract *ExtractError
+	if assert.ErrorAs(t, details.Err, &errExtract) {
+		assert.Equal(t, "details", errExtract.What)
+	}
+	assert.Nil(t, details.Value)
+
+	assert.NoError(t, similar.Err)
+	assert.Equal(t, []SimilarApp{}, similar.Value)
+
+	assert.ErrorIs(t, dataSafety.Err, ErrNoEnvelope)
+	assert.Nil(t, dataSafety.Value)
+}
+
+func TestSendBatchedRequestsOrder(t *testing.T) {
+	// Envelopes do not have to come back in the same order as the requests
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		{"wrb.fr", "ag2B9c", "", nil, nil, nil, "1"},
+		{"wrb.fr", "ag2B9c", "[]", nil, nil, nil, "0"},
+	})
+
+	requesters := []BatchRequester[[]SimilarApp]{
+		NewSimilarBatchRequester("com.example.app"),
+		NewSimilarBatchRequester(nonExistentAppId),
+	}
+
+	results, err := SendBatchedRequests(context.Background(), client, "us", "en", requesters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrAppNotFound)
+}
+
+func TestBatchMaxSize(t *testing.T) {
+	var numRequests int
+
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			// Ignore the request for the session
+			if req.Method == "GET" {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+			numRequests++
+
+			// Respond to every RPC in the HTTP request with an empty list of similar apps
+			rpcids := strings.Split(req.URL.Query().Get("rpcids"), ",")
+			var envelopes [][]interface{}
+			for i, rpcid := range rpcids {
+				envelopes = append(envelopes, []interface{}{"wrb.fr", rpcid, "[]", nil, nil, nil, strconv.Itoa(i)})
+			}
+
+			body, err := json.Marshal(envelopes)
+			if err != nil {
+				return nil, err
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(")]}'\n\n" + string(body))),
+				Header:     make(http.Header),
+				Request:    req,
+			}, nil
+		}),
+	}
+
+	batch := &Batch{MaxSize: 3}
+	var results []*Result[[]SimilarApp]
+	for i := 0; i < 7; i++ {
+		results = append(results, Add(batch, NewSimilarBatchRequester(fmt.Sprintf("com.example.app%d", i))))
+	}
+
+	if err := batch.Send(context.Background(), client, "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, numRequests)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+}
+
+func TestBatchErrorEnvelopes(t *testing.T) {
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		{"wrb.fr", "Ws7gDc", nil, nil, nil, []int{3}, "0"},
+		{"wrb.fr", "Ws7gDc", nil, nil, nil, []int{5}, "1"},
+		{"wrb.fr", "ag2B9c", nil, nil, nil, []int{8}, "generic"},
+		{"di", 44},
+		{"af.httprm", 43, "-1234", 2},
+	})
+
+	batch := &Batch{}
+	rejected := Add(batch, NewDetailsBatchRequester("com.example.app"))
+	notFound := Add(batch, NewDetailsBatchRequester(nonExistentAppId))
+	exhausted := Add(batch, NewSimilarBatchRequester("com.example.app"))
+
+	if err := batch.Send(context.Background(), client, "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	var errBatch *BatchError
+	if assert.ErrorAs(t, rejected.Err, &errBatch) {
+		assert.Equal(t, &BatchError{RpcId: "Ws7gDc", Code: StatusInvalidArgument, Number: 0}, errBatch)
+		assert.True(t, errBatch.SchemaChanged())
+	}
+
+	assert.ErrorIs(t, notFound.Err, ErrAppNotFound)
+
+	// The error without a number is matched up by the RPC ID
+	if assert.ErrorAs(t, exhausted.Err, &errBatch) {
+		assert.Equal(t, StatusResourceExhausted, errBatch.Code)
+	}
+	assert.ErrorIs(t, exhausted.Err, ErrRateLimited)
+}
+
+func TestBatchRejected(t *testing.T) {
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		{"er", nil, nil, nil, nil, 400, nil, nil, nil, 3},
+	})
+
+	batch := &Batch{}
+	Add(batch, NewDetailsBatchRequester("com.example.app"))
+
+	err := batch.Send(context.Background(), client, "us", "en")
+
+	var errRejected *BatchRejectedError
+	if assert.ErrorAs(t, err, &errRejected) {
+		assert.Equal(t, http.StatusBadRequest, errRejected.Status)
+		assert.Equal(t, []int{0}, errRejected.Requests)
+	}
+}
+
+func TestBatchRejectedPartly(t *testing.T) {
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			// Ignore the request for the session
+			if req.Method == "GET" {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+
+			if err := req.ParseForm(); err != nil {
+				return nil, err
+			}
+
+			// Google does not like one of the apps, so rejects the HTTP request it is in
+			envelopes := [][]interface{}{{"er", nil, nil, nil, nil, 400, nil, nil, nil, 3}}
+			if !strings.Contains(req.PostForm.Get("f.req"), "com.example.bad") {
+				envelopes = [][]interface{}{
+					{"wrb.fr", "ag2B9c", "[]", nil, nil, nil, "0"},
+					{"wrb.fr", "ag2B9c", "[]", nil, nil, nil, "1"},
+				}
+			}
+
+			body, err := json.Marshal(envelopes)
+			if err != nil {
+				return nil, err
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(")]}'\n\n" + string(body))),
+				Header:     make(http.Header),
+				Request:    req,
+			}, nil
+		}),
+	}
+
+	batch := &Batch{MaxSize: 2}
+	var results []*Result[[]SimilarApp]
+	for _, appId := range []string{"com.example.app0", "com.example.app1", "com.example.bad", "com.example.app3"} {
+		results = append(results, Add(batch, NewSimilarBatchRequester(appId)))
+	}
+
+	err := batch.Send(context.Background(), client, "us", "en")
+
+	// Only the requests that were sent with the bad one are rejected
+	var errRejected *BatchRejectedError
+	if assert.ErrorAs(t, err, &errRejected) {
+		assert.Equal(t, []int{2, 3}, errRejected.Requests)
+	}
+
+	assert.NoError(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.ErrorAs(t, results[2].Err, &errRejected)
+	assert.ErrorAs(t, results[3].Err, &errRejected)
+}
+
+func TestBatchRejectedInvalidStatus(t *testing.T) {
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		{"er", nil, nil, nil, nil, "bad", nil, nil, nil, 3},
+	})
+
+	batch := &Batch{}
+	Add(batch, NewDetailsBatchRequester("com.example.app"))
+
+	err := batch.Send(context.Background(), client, "us", "en")
+
+	var errRejected *BatchRejectedError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &errRejected))
+}
+
+func TestBatchNotFoundWithoutNumber(t *testing.T) {
+	// This is how Google usually reports an app that does not exist
+	client := fakeBatchExecuteClient(t, [][]interface{}{
+		{"wrb.fr", "Ws7gDc", "[]", nil, nil, nil, "0"},
+		{"wrb.fr", "Ws7gDc", nil, nil, nil, []interface{}{5, nil, []interface{}{}}, "generic"},
+		{"di", 44},
+		{"af.httprm", 43, "-1234", 2},
+	})
+
+	batch := &Batch{}
+	Add(batch, NewSimilarBatchRequester("com.example.app"))
+	notFound := Add(batch, NewDetailsBatchRequester(nonExistentAppId))
+	alsoNotFound := Add(batch, NewDataSafetyRequester(nonExistentAppId))
+
+	if err := batch.Send(context.Background(), client, "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, notFound.Err, ErrAppNotFound)
+	assert.ErrorIs(t, alsoNotFound.Err, ErrAppNotFound)
+}
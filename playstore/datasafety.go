@@ -97,7 +97,7 @@ type dataSafetyRequester struct {
 	AppId string
 }
 
-func NewDataSafetyRequester(appId string) *dataSafetyRequester {
+func NewDataSafetyRequester(appId string) BatchRequester[*DataSafety] {
 	return &dataSafetyRequester{AppId: appId}
 }
 
@@ -108,7 +108,7 @@ func (br *dataSafetyRequester) BatchRequest() batchRequest {
 	}
 }
 
-func (br *dataSafetyRequester) ParseEnvelope(payload string) (interface{}, error) {
+func (br *dataSafetyRequester) ParseEnvelope(payload string) (*DataSafety, error) {
 	if len(payload) == 0 {
 		return nil, ErrAppNotFound
 	}
@@ -165,26 +165,5 @@ func (br *dataSafetyRequester) ParseEnvelope(payload string) (interface{}, error
 }
 
 func ScrapeDataSafety(ctx context.Context, client *http.Client, appId string) (*DataSafety, error) {
-	requester := NewDataSafetyRequester(appId)
-
-	envelopes, err := sendRequests(ctx, client, "us", "en", []BatchRequester{requester})
-	if err != nil {
-		return nil, err
-	}
-
-	if len(envelopes) == 0 {
-		return nil, fmt.Errorf("no envelope")
-	}
-
-	envelope := envelopes[0]
-
-	ds, err := requester.ParseEnvelope(envelope.Payload)
-	if err != nil {
-		return nil, err
-	}
-
-	if ds == nil {
-		return nil, nil
-	}
-	return ds.(*DataSafety), nil
+	return sendRequest(ctx, client, "us", "en", NewDataSafetyRequester(appId))
 }
@@ -2,14 +2,17 @@ package playstore
 
 import (
 	"context"
-	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
 )
 
 func TestDataSafety(t *testing.T) {
-	dataSafety, err := ScrapeDataSafety(context.Background(), http.DefaultClient, "com.google.android.googlequicksearchbox")
+	client := httprecord.Client(t)
+
+	dataSafety, err := ScrapeDataSafety(context.Background(), client, "com.google.android.googlequicksearchbox")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -28,13 +31,17 @@ func TestDataSafety(t *testing.T) {
 }
 
 func TestDataSafetyAppDoesNotExist(t *testing.T) {
-	dataSafety, err := ScrapeDataSafety(context.Background(), http.DefaultClient, "abcdefghijklmnopqrstuvwxyz")
+	client := httprecord.Client(t)
+
+	dataSafety, err := ScrapeDataSafety(context.Background(), client, "abcdefghijklmnopqrstuvwxyz")
 	assert.Nil(t, dataSafety)
 	assert.Equal(t, ErrAppNotFound, err)
 }
 
 func TestDataSafetyNoInfoYet(t *testing.T) {
-	dataSafety, err := ScrapeDataSafety(context.Background(), http.DefaultClient, "bbc.mobile.news.uk")
+	client := httprecord.Client(t)
+
+	dataSafety, err := ScrapeDataSafety(context.Background(), client, "bbc.mobile.news.uk")
 	if err != nil {
 		t.Fatal(err)
 	}
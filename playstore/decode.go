@@ -0,0 +1,246 @@
+package playstore
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Instead of extracting each field by hand, structs can say where their fields are in
+// the payload with tags, e.g.
+//
+//	Title   string      `playstore:"1.2.0.0"`
+//	Ratings int64       `playstore:"1.2.51.2.1,optional"`
+//	Price   null.Float  `playstore:"1.2.57.0.0.0.0.1.0.0,micros"`
+//
+// The options are:
+//   - optional: a null value is allowed and gives the zero value. Fields of the null
+//     types (null.String etc.) are always optional.
+//   - micros: the value is in millionths, as prices are, and is divided by 1000000.
+//
+// The paths of fields in a nested struct are relative to the path of the struct field.
+// Fields without a tag are left alone, so that they can be filled in by hand.
+const tagName = "playstore"
+
+// A field of a struct and the path in the payload that it is extracted from
+type FieldPath struct {
+	Field    string
+	Path     string
+	Optional bool
+	Micros   bool
+
+	index []int
+}
+
+var fieldPathsCache sync.Map
+
+// The paths of the fields of a struct. This is useful for debugging when Google moves
+// things around.
+func Paths(v interface{}) []FieldPath {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return fieldPaths(t)
+}
+
+func fieldPaths(t reflect.Type) []FieldPath {
+	if paths, ok := fieldPathsCache.Load(t); ok {
+		return paths.([]FieldPath)
+	}
+
+	paths := appendFieldPaths(nil, t, "", "", nil)
+	fieldPathsCache.Store(t, paths)
+	return paths
+}
+
+func appendFieldPaths(paths []FieldPath, t reflect.Type, fieldPrefix string, pathPrefix string, index []int) []FieldPath {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok || tag == "-" {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		fieldPath := FieldPath{
+			Field: fieldPrefix + field.Name,
+			Path:  pathPrefix + options[0],
+			index: append(append([]int{}, index...), i),
+		}
+
+		for _, option := range options[1:] {
+			switch option {
+			case "optional":
+				fieldPath.Optional = true
+			case "micros":
+				fieldPath.Micros = true
+			default:
+				panic(fmt.Sprintf("%s: unknown option '%s'", fieldPath.Field, option))
+			}
+		}
+
+		if field.Type.Kind() == reflect.Struct && !isLeafType(field.Type) {
+			paths = appendFieldPaths(paths, field.Type, fieldPath.Field+".", fieldPath.Path+".", fieldPath.index)
+			continue
+		}
+
+		if !isLeafType(field.Type) {
+			panic(fmt.Sprintf("%s: unsupported type %s", fieldPath.Field, field.Type))
+		}
+
+		paths = append(paths, fieldPath)
+	}
+
+	return paths
+}
+
+var (
+	typeString      = reflect.TypeOf("")
+	typeInt64       = reflect.TypeOf(int64(0))
+	typeFloat64     = reflect.TypeOf(float64(0))
+	typeBool        = reflect.TypeOf(false)
+	typeTime        = reflect.TypeOf(time.Time{})
+	typeStringSlice = reflect.TypeOf([]string{})
+	typeFloatSlice  = reflect.TypeOf([]float64{})
+	typeNullString  = reflect.TypeOf(null.String{})
+	typeNullInt     = reflect.TypeOf(null.Int{})
+	typeNullFloat   = reflect.TypeOf(null.Float{})
+	typeNullBool    = reflect.TypeOf(null.Bool{})
+	typeNullTime    = reflect.TypeOf(null.Time{})
+	typeNullFloats  = reflect.TypeOf([]null.Float{})
+)
+
+func isLeafType(t reflect.Type) bool {
+	switch t {
+	case typeString, typeInt64, typeFloat64, typeBool, typeTime, typeStringSlice, typeFloatSlice,
+		typeNullString, typeNullInt, typeNullFloat, typeNullBool, typeNullTime, typeNullFloats:
+		return true
+	default:
+		return false
+	}
+}
+
+// Fill the tagged fields of v, which must be a pointer to a struct. Errors are collected
+// in the extractor like for the other methods.
+func (e *extractor) Decode(v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+
+	for _, fieldPath := range fieldPaths(rv.Type()) {
+		fieldPath := fieldPath
+		e.Observe(rv.Type().Name()+"."+fieldPath.Field, fieldPath.Path, func() {
+			e.decodeField(rv.FieldByIndex(fieldPath.index), fieldPath)
+		})
+	}
+}
+
+// Extract a path with extract and report what happened to it to the monitor, if there is
+// one, like Decode does for the tagged fields. This is for the fields that are extracted
+// by hand. The extraction failed if extract adds any errors to the extractor.
+func (e *extractor) Observe(field string, path string, extract func()) {
+	if e.monitor == nil {
+		extract()
+		return
+	}
+
+	numErrors := len(e.errors)
+	extract()
+	failed := len(e.errors) > numErrors
+
+	outcome := outcomeOk
+	switch result := e.get(path); {
+	case !result.Exists():
+		outcome = outcomeMissing
+	case result.Type == gjson.Null:
+		outcome = outcomeNull
+	case failed:
+		outcome = outcomeWrongType
+	}
+
+	e.monitor.observe(field, path, outcome, failed)
+}
+
+func (e *extractor) decodeField(v reflect.Value, f FieldPath) {
+	path := f.Path
+
+	switch v.Type() {
+	case typeString:
+		if f.Optional {
+			v.SetString(e.OptionalString(path).ValueOrZero())
+		} else {
+			v.SetString(e.String(path))
+		}
+
+	case typeInt64:
+		if f.Optional {
+			v.SetInt(e.OptionalInt(path).ValueOrZero())
+		} else {
+			v.SetInt(e.Int(path))
+		}
+
+	case typeFloat64:
+		var x float64
+		if f.Optional {
+			x = e.OptionalFloat(path).ValueOrZero()
+		} else {
+			x = e.Float(path)
+		}
+		if f.Micros {
+			x = price(x)
+		}
+		v.SetFloat(x)
+
+	case typeBool:
+		if f.Optional {
+			v.SetBool(e.OptionalBool(path).ValueOrZero())
+		} else {
+			v.SetBool(e.Bool(path))
+		}
+
+	case typeTime:
+		if f.Optional {
+			v.Set(reflect.ValueOf(e.OptionalTime(path).ValueOrZero()))
+		} else {
+			v.Set(reflect.ValueOf(e.Time(path)))
+		}
+
+	case typeStringSlice:
+		if f.Optional {
+			v.Set(reflect.ValueOf(e.OptionalStringSlice(path)))
+		} else {
+			v.Set(reflect.ValueOf(e.StringSlice(path)))
+		}
+
+	case typeFloatSlice:
+		v.Set(reflect.ValueOf(e.FloatSlice(path)))
+
+	case typeNullString:
+		v.Set(reflect.ValueOf(e.OptionalString(path)))
+
+	case typeNullInt:
+		v.Set(reflect.ValueOf(e.OptionalInt(path)))
+
+	case typeNullFloat:
+		x := e.OptionalFloat(path)
+		if f.Micros {
+			x = maybePrice(x)
+		}
+		v.Set(reflect.ValueOf(x))
+
+	case typeNullBool:
+		v.Set(reflect.ValueOf(e.OptionalBool(path)))
+
+	case typeNullTime:
+		v.Set(reflect.ValueOf(e.OptionalTime(path)))
+
+	case typeNullFloats:
+		v.Set(reflect.ValueOf(e.OptionalFloatSlice(path)))
+	}
+}
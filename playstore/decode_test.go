@@ -0,0 +1,76 @@
+package playstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+)
+
+type decodeTestInner struct {
+	Count int64 `playstore:"0"`
+}
+
+type decodeTestStruct struct {
+	Name      string          `playstore:"0.0"`
+	Missing   string          `playstore:"0.1,optional"`
+	Price     float64         `playstore:"1,micros"`
+	Sale      null.Float      `playstore:"2,micros"`
+	Released  null.Time       `playstore:"3"`
+	Tags      []string        `playstore:"4.#.0,optional"`
+	Inner     decodeTestInner `playstore:"5"`
+	Untouched string
+}
+
+func TestDecode(t *testing.T) {
+	extract := NewExtractor(`[["name",null],1990000,null,1389327329,[["a"],["b"]],[3]]`)
+
+	v := decodeTestStruct{Untouched: "untouched"}
+	extract.Decode(&v)
+
+	assert.Empty(t, extract.Errors())
+	assert.Equal(t, decodeTestStruct{
+		Name:      "name",
+		Missing:   "",
+		Price:     1.99,
+		Sale:      null.Float{},
+		Released:  null.TimeFrom(time.Date(2014, time.January, 10, 4, 15, 29, 0, time.UTC)),
+		Tags:      []string{"a", "b"},
+		Inner:     decodeTestInner{Count: 3},
+		Untouched: "untouched",
+	}, v)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	extract := NewExtractor(`[[null,1],"not a number",null,null,null,["three"]]`)
+
+	var v decodeTestStruct
+	extract.Decode(&v)
+
+	// Every bad path is reported, not just the first one
+	errors := extract.Errors()
+	if assert.Len(t, errors, 4) {
+		assert.Contains(t, errors[0].Error(), "0.0")
+		assert.Contains(t, errors[1].Error(), "0.1")
+		assert.Contains(t, errors[2].Error(), "1")
+		assert.Contains(t, errors[3].Error(), "5.0")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	paths := Paths(&Details{})
+
+	byField := make(map[string]FieldPath)
+	for _, path := range paths {
+		byField[path.Field] = path
+	}
+
+	assert.Equal(t, "1.2.0.0", byField["Title"].Path)
+	assert.Equal(t, "1.2.51.1.1.1", byField["Histogram.Stars1"].Path)
+	assert.True(t, byField["Ratings"].Optional)
+	assert.True(t, byField["Price"].Micros)
+
+	// Fields that are filled in by hand do not have paths
+	assert.NotContains(t, byField, "DeveloperId")
+}
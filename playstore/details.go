@@ -6,71 +6,72 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
-	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
 	"gopkg.in/guregu/null.v4"
 )
 
+// The playstore tags give the paths of the fields in the payload (see decode.go). The
+// fields without tags need some more work and are filled in by hand.
 type Details struct {
-	Title                    string       `json:"title"`
+	Title                    string       `json:"title" playstore:"1.2.0.0"`
 	Description              string       `json:"description"`
-	DescriptionHTML          string       `json:"description_html"`
-	Summary                  null.String  `json:"summary"`
-	Installs                 null.String  `json:"installs"`
-	MinInstalls              null.Int     `json:"min_installs"`
-	MaxInstalls              null.Int     `json:"max_installs"`
-	Score                    null.Float   `json:"score"`
-	ScoreText                null.String  `json:"score_text"`
-	Ratings                  int64        `json:"ratings"`
-	Reviews                  int64        `json:"reviews"`
-	Histogram                Histogram    `json:"histogram"`
-	Price                    float64      `json:"price"`
-	Currency                 null.String  `json:"currency"`
-	PriceText                string       `json:"price_text"`
-	SaleEndTime              null.Time    `json:"sale_end_time"`
-	OriginalPrice            null.Float   `json:"original_price"`
-	OriginalPriceText        null.String  `json:"original_price_text"`
+	DescriptionHTML          string       `json:"description_html" playstore:"1.2.72.0.1"`
+	Summary                  null.String  `json:"summary" playstore:"1.2.73.0.1"`
+	Installs                 null.String  `json:"installs" playstore:"1.2.13.0"`
+	MinInstalls              null.Int     `json:"min_installs" playstore:"1.2.13.1"`
+	MaxInstalls              null.Int     `json:"max_installs" playstore:"1.2.13.2"`
+	Score                    null.Float   `json:"score" playstore:"1.2.51.0.1"`
+	ScoreText                null.String  `json:"score_text" playstore:"1.2.51.0.0"`
+	Ratings                  int64        `json:"ratings" playstore:"1.2.51.2.1,optional"`
+	Reviews                  int64        `json:"reviews" playstore:"1.2.51.3.1,optional"`
+	Histogram                Histogram    `json:"histogram" playstore:"1.2.51.1"`
+	Price                    float64      `json:"price" playstore:"1.2.57.0.0.0.0.1.0.0,optional,micros"`
+	Currency                 null.String  `json:"currency" playstore:"1.2.57.0.0.0.0.1.0.1"`
+	PriceText                string       `json:"price_text" playstore:"1.2.57.0.0.0.0.1.0.2,optional"`
+	SaleEndTime              null.Time    `json:"sale_end_time" playstore:"1.2.57.0.0.0.0.14.0.0"`
+	OriginalPrice            null.Float   `json:"original_price" playstore:"1.2.57.0.0.0.0.1.1.0,micros"`
+	OriginalPriceText        null.String  `json:"original_price_text" playstore:"1.2.57.0.0.0.0.1.1.2"`
 	SaleText                 null.String  `json:"sale_text"`
 	Available                bool         `json:"available"`
 	OffersIAP                bool         `json:"in_app_purchases"`
-	IAPRange                 null.String  `json:"in_app_purchases_range"`
+	IAPRange                 null.String  `json:"in_app_purchases_range" playstore:"1.2.19.0"`
 	Size                     string       `json:"size"`
-	MinAPILevel              null.Int     `json:"min_api"`
-	TargetAPILevel           null.Int     `json:"target_api"`
-	MinAndroidVersion        null.String  `json:"min_android_version"`
-	Developer                string       `json:"developer"`
+	MinAPILevel              null.Int     `json:"min_api" playstore:"1.2.140.1.1.0.0.0"`
+	TargetAPILevel           null.Int     `json:"target_api" playstore:"1.2.140.1.0.0.0"`
+	MinAndroidVersion        null.String  `json:"min_android_version" playstore:"1.2.140.1.1.0.0.1"`
+	Developer                string       `json:"developer" playstore:"1.2.68.0"`
 	DeveloperId              string       `json:"developer_id"`
-	DeveloperEmail           null.String  `json:"developer_email"`
-	DeveloperWebsite         null.String  `json:"developer_website"`
-	DeveloperAddress         null.String  `json:"developer_address"`
-	PrivacyPolicy            null.String  `json:"privacy_policy"`
-	Genre                    string       `json:"genre_id"`
-	AdditionalGenres         []string     `json:"additional_genre_ids"`
-	TeacherApprovedAge       null.String  `json:"teacher_approved_age"`
-	Icon                     null.String  `json:"icon"`
-	HeaderImage              null.String  `json:"header_image"`
-	Screenshots              []string     `json:"screenshots"`
-	Video                    null.String  `json:"video"`
-	VideoImage               null.String  `json:"video_image"`
-	ContentRating            null.String  `json:"content_rating"`
-	ContentRatingDescription null.String  `json:"content_rating_description"`
+	DeveloperEmail           null.String  `json:"developer_email" playstore:"1.2.69.1.0"`
+	DeveloperWebsite         null.String  `json:"developer_website" playstore:"1.2.69.0.5.2"`
+	DeveloperAddress         null.String  `json:"developer_address" playstore:"1.2.69.2.0"`
+	PrivacyPolicy            null.String  `json:"privacy_policy" playstore:"1.2.99.0.5.2"`
+	Genre                    string       `json:"genre_id" playstore:"1.2.79.0.0.2"`
+	AdditionalGenres         []string     `json:"additional_genre_ids" playstore:"1.2.118.#.0.0.2,optional"`
+	TeacherApprovedAge       null.String  `json:"teacher_approved_age" playstore:"1.2.111.1"`
+	Icon                     null.String  `json:"icon" playstore:"1.2.95.0.3.2"`
+	HeaderImage              null.String  `json:"header_image" playstore:"1.2.96.0.3.2"`
+	Screenshots              []string     `json:"screenshots" playstore:"1.2.78.0.#.3.2,optional"`
+	Video                    null.String  `json:"video" playstore:"1.2.100.0.0.3.2"`
+	VideoImage               null.String  `json:"video_image" playstore:"1.2.100.0.1.3.2"`
+	ContentRating            null.String  `json:"content_rating" playstore:"1.2.9.0"`
+	ContentRatingDescription null.String  `json:"content_rating_description" playstore:"1.2.9.6.1"`
 	AdSupported              bool         `json:"ad_supported"`
-	Released                 null.Time    `json:"released"`
-	Updated                  time.Time    `json:"updated"`
-	Version                  null.String  `json:"version"`
-	RecentChanges            null.String  `json:"recent_changes"`
-	RecentChangesTime        null.Time    `json:"recent_changes_time"`
+	Released                 null.Time    `json:"released" playstore:"1.2.10.1.0"`
+	Updated                  time.Time    `json:"updated" playstore:"1.2.145.0.1.0"`
+	Version                  null.String  `json:"version" playstore:"1.2.140.0.0.0"`
+	RecentChanges            null.String  `json:"recent_changes" playstore:"1.2.144.1.1"`
+	RecentChangesTime        null.Time    `json:"recent_changes_time" playstore:"1.2.144.2.0"`
 	Permissions              []Permission `json:"permissions"`
 }
 
 type Histogram struct {
-	Stars1 int64 `json:"1"`
-	Stars2 int64 `json:"2"`
-	Stars3 int64 `json:"3"`
-	Stars4 int64 `json:"4"`
-	Stars5 int64 `json:"5"`
+	Stars1 int64 `json:"1" playstore:"1.1,optional"`
+	Stars2 int64 `json:"2" playstore:"2.1,optional"`
+	Stars3 int64 `json:"3" playstore:"3.1,optional"`
+	Stars4 int64 `json:"4" playstore:"4.1,optional"`
+	Stars5 int64 `json:"5" playstore:"5.1,optional"`
 }
 
 type Permission struct {
@@ -84,7 +85,7 @@ type detailsBatchRequester struct {
 	AppId string
 }
 
-func NewDetailsBatchRequester(appId string) *detailsBatchRequester {
+func NewDetailsBatchRequester(appId string) BatchRequester[*Details] {
 	return &detailsBatchRequester{AppId: appId}
 }
 
@@ -95,108 +96,56 @@ func (br *detailsBatchRequester) BatchRequest() batchRequest {
 	}
 }
 
-func (br *detailsBatchRequester) ParseEnvelope(payload string) (interface{}, error) {
+func (br *detailsBatchRequester) ParseEnvelope(payload string) (*Details, error) {
+	return br.parseEnvelope(payload, nil)
+}
+
+func (br *detailsBatchRequester) parseEnvelope(payload string, monitor *Monitor) (*Details, error) {
 	if payload == "" {
 		return nil, ErrAppNotFound
 	}
 
 	extract := NewExtractor(payload)
+	extract.monitor = monitor
 
-	descriptionHTML := extract.String("1.2.72.0.1")
-	description, err := textFromHTML(descriptionHTML)
-	if err != nil {
-		extract.Error(fmt.Errorf("app description contains invalid HTML"))
-	}
-
-	iap := extract.OptionalString("1.2.19.0")
+	var details Details
+	extract.Decode(&details)
 
-	permissions, err := extractPermissions(extract.Json("1.2.74.2"))
+	description, err := textFromHTML(details.DescriptionHTML)
 	if err != nil {
-		extract.Error(fmt.Errorf("permissions: %w", err))
+		extract.Error(fmt.Errorf("app description contains invalid HTML"))
 	}
 
-	details := Details{
-		Title:           extract.String("1.2.0.0"),
-		Description:     description,
-		DescriptionHTML: descriptionHTML,
-		Summary:         extract.OptionalString("1.2.73.0.1"),
-		Installs:        extract.OptionalString("1.2.13.0"),
-		MinInstalls:     extract.OptionalInt("1.2.13.1"),
-		MaxInstalls:     extract.OptionalInt("1.2.13.2"),
-		Score:           extract.OptionalFloat("1.2.51.0.1"),
-		ScoreText:       extract.OptionalString("1.2.51.0.0"),
-		Ratings:         extract.OptionalInt("1.2.51.2.1").ValueOrZero(),
-		Reviews:         extract.OptionalInt("1.2.51.3.1").ValueOrZero(),
-		Histogram: Histogram{
-			Stars1: extract.OptionalInt("1.2.51.1.1.1").ValueOrZero(),
-			Stars2: extract.OptionalInt("1.2.51.1.2.1").ValueOrZero(),
-			Stars3: extract.OptionalInt("1.2.51.1.3.1").ValueOrZero(),
-			Stars4: extract.OptionalInt("1.2.51.1.4.1").ValueOrZero(),
-			Stars5: extract.OptionalInt("1.2.51.1.5.1").ValueOrZero(),
-		},
-		Price:                    price(extract.OptionalFloat("1.2.57.0.0.0.0.1.0.0").ValueOrZero()),
-		Currency:                 extract.OptionalString("1.2.57.0.0.0.0.1.0.1"),
-		PriceText:                priceText(extract.OptionalString("1.2.57.0.0.0.0.1.0.2").ValueOrZero()),
-		SaleEndTime:              extract.OptionalTime("1.2.57.0.0.0.0.14.0.0"),
-		OriginalPrice:            maybePrice(extract.OptionalFloat("1.2.57.0.0.0.0.1.1.0")),
-		OriginalPriceText:        extract.OptionalString("1.2.57.0.0.0.0.1.1.2"),
-		Available:                extract.Int("1.2.42.0") == 1, // This seems to be 3 on countries where apps are not available, e.g. iPlayer outside UK
-		OffersIAP:                iap.Valid && iap.String != "",
-		IAPRange:                 iap,
-		MinAPILevel:              extract.OptionalInt("1.2.140.1.1.0.0.0"),
-		TargetAPILevel:           extract.OptionalInt("1.2.140.1.0.0.0"),
-		MinAndroidVersion:        extract.OptionalString("1.2.140.1.1.0.0.1"),
-		Developer:                extract.String("1.2.68.0"),
-		DeveloperId:              developerId(extract, "1.2.68.1.4.2"),
-		DeveloperEmail:           extract.OptionalString("1.2.69.1.0"),
-		DeveloperWebsite:         extract.OptionalString("1.2.69.0.5.2"),
-		DeveloperAddress:         extract.OptionalString("1.2.69.2.0"),
-		PrivacyPolicy:            extract.OptionalString("1.2.99.0.5.2"),
-		Genre:                    extract.String("1.2.79.0.0.2"),
-		AdditionalGenres:         extract.OptionalStringSlice("1.2.118.#.0.0.2"),
-		TeacherApprovedAge:       extract.OptionalString("1.2.111.1"),
-		Icon:                     extract.OptionalString("1.2.95.0.3.2"),
-		HeaderImage:              extract.OptionalString("1.2.96.0.3.2"),
-		Screenshots:              extract.OptionalStringSlice("1.2.78.0.#.3.2"),
-		Video:                    extract.OptionalString("1.2.100.0.0.3.2"),
-		VideoImage:               extract.OptionalString("1.2.100.0.1.3.2"),
-		ContentRating:            extract.OptionalString("1.2.9.0"),
-		ContentRatingDescription: extract.OptionalString("1.2.9.6.1"),
-		AdSupported:              !extract.IsNull("1.2.48.0"),
-		Released:                 extract.OptionalTime("1.2.10.1.0"),
-		Updated:                  extract.Time("1.2.145.0.1.0"),
-		Version:                  extract.OptionalString("1.2.140.0.0.0"),
-		RecentChanges:            extract.OptionalString("1.2.144.1.1"),
-		RecentChangesTime:        extract.OptionalTime("1.2.144.2.0"),
-		Permissions:              permissions,
-	}
+	extract.Observe("Details.Permissions", "1.2.74.2", func() {
+		permissions, err := extractPermissions(extract, "1.2.74.2")
+		if err != nil {
+			extract.Error(fmt.Errorf("permissions: %w", err))
+		}
+		details.Permissions = permissions
+	})
+	extract.Observe("Details.Available", "1.2.42.0", func() {
+		details.Available = extract.Int("1.2.42.0") == 1 // This seems to be 3 on countries where apps are not available, e.g. iPlayer outside UK
+	})
+	extract.Observe("Details.DeveloperId", "1.2.68.1.4.2", func() {
+		details.DeveloperId = developerId(extract, "1.2.68.1.4.2")
+	})
+	extract.Observe("Details.AdSupported", "1.2.48.0", func() {
+		details.AdSupported = !extract.IsNull("1.2.48.0")
+	})
+
+	details.Description = description
+	details.PriceText = priceText(details.PriceText)
+	details.OffersIAP = details.IAPRange.Valid && details.IAPRange.String != ""
 
 	if extract.Errors() != nil {
-		err = &DetailsExtractError{
-			Errors:  extract.Errors(),
-			Payload: payload,
-		}
-		return nil, err
+		return nil, &ExtractError{What: "details", Errors: extract.Errors(), Payload: payload}
 	}
 
 	return &details, nil
 }
 
-type DetailsExtractError struct {
-	Errors  []error
-	Payload string
-}
-
-func (e *DetailsExtractError) Error() string {
-	sb := strings.Builder{}
-
-	sb.WriteString("Error extracting details apps:\n")
-	for _, err := range e.Errors {
-		sb.WriteString(fmt.Sprintf("\t- %s\n", err.Error()))
-	}
-
-	return sb.String()
-}
+// Deprecated: details are extracted into an ExtractError, like everything else.
+type DetailsExtractError = ExtractError
 
 var developerIdRe = regexp.MustCompile(`^/store/apps/developer\?id=(.*)$`)
 
@@ -251,40 +200,44 @@ func priceText(priceText string) string {
 	return priceText
 }
 
-func extractPermissions(val gjson.Result) ([]Permission, error) {
+func extractPermissions(e *extractor, path string) ([]Permission, error) {
 	var permissions []Permission
 
+	val := e.Json(path)
 	if !(val.IsArray() || val.Type == gjson.Null) {
 		return nil, fmt.Errorf("expected an array")
 	}
 
-	for _, subVal := range val.Array() {
-		if !val.IsArray() {
+	numGroups := int(e.Json(path + ".#").Int())
+	for i := 0; i < numGroups; i++ {
+		groupPath := fmt.Sprintf("%s.%d", path, i)
+		if !e.Json(groupPath).IsArray() {
 			return nil, fmt.Errorf("expected an array")
 		}
 
-		for _, rawPerm := range subVal.Array() {
-			if !rawPerm.IsArray() {
+		numPerms := int(e.Json(groupPath + ".#").Int())
+		for j := 0; j < numPerms; j++ {
+			// Use a sub-extractor so the permission is not parsed again from scratch
+			extract := e.Sub(fmt.Sprintf("%s.%d", groupPath, j))
+
+			if !extract.Json("").IsArray() {
 				return nil, fmt.Errorf("expected an array")
 			}
 
-			rawPermArray := rawPerm.Array()
-
-			if len(rawPermArray) == 0 {
+			switch extract.Json("#").Int() {
+			case 0:
 				continue
-			} else if len(rawPermArray) == 4 {
-				extract := NewExtractor(rawPerm.String())
+			case 4:
 				group := extract.String("0")
 				perms := extract.StringSlice("2.#.1")
 
 				for _, perm := range perms {
 					permissions = append(permissions, Permission{Group: group, Permission: perm})
 				}
-			} else if len(rawPermArray) == 2 {
-				extract := NewExtractor(rawPerm.String())
+			case 2:
 				perm := extract.String("1")
 				permissions = append(permissions, Permission{Group: "Other", Permission: perm})
-			} else {
+			default:
 				return nil, fmt.Errorf("expected an array of length 2 or 4")
 			}
 		}
@@ -294,21 +247,5 @@ func extractPermissions(val gjson.Result) ([]Permission, error) {
 }
 
 func ScrapeDetails(ctx context.Context, client *http.Client, appId string, country string, language string) (*Details, error) {
-	requester := NewDetailsBatchRequester(appId)
-	envelopes, err := sendRequests(ctx, client, country, language, []BatchRequester{requester})
-	if err != nil {
-		return nil, err
-	}
-
-	if len(envelopes) == 0 {
-		return nil, fmt.Errorf("no envelope")
-	}
-	envelope := envelopes[0]
-
-	details, err := requester.ParseEnvelope(envelope.Payload)
-	if err != nil {
-		return nil, err
-	}
-
-	return details.(*Details), nil
+	return sendRequest(ctx, client, country, language, NewDetailsBatchRequester(appId))
 }
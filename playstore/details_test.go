@@ -3,23 +3,28 @@ package playstore
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
 )
 
 const nonExistentAppId = "This.App.Id.Does.Not.Exist.Hopefully.12345"
 
 func TestNotFound(t *testing.T) {
-	_, err := ScrapeDetails(context.Background(), http.DefaultClient, nonExistentAppId, "us", "en")
+	client := httprecord.Client(t)
+
+	_, err := ScrapeDetails(context.Background(), client, nonExistentAppId, "us", "en")
 	assert.ErrorIs(t, err, ErrAppNotFound)
 }
 
 func TestScrapeDetails(t *testing.T) {
-	details, err := ScrapeDetails(context.Background(), http.DefaultClient, "com.sgn.pandapop.gp", "us", "en")
+	client := httprecord.Client(t)
+
+	details, err := ScrapeDetails(context.Background(), client, "com.sgn.pandapop.gp", "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,8 +100,10 @@ func TestScrapeDetails(t *testing.T) {
 }
 
 func TestDetails2(t *testing.T) {
+	client := httprecord.Client(t)
+
 	// com.tocaboca.tocakitchen2
-	details, err := ScrapeDetails(context.Background(), http.DefaultClient, "com.tocaboca.tocakitchen2", "us", "en")
+	details, err := ScrapeDetails(context.Background(), client, "com.tocaboca.tocakitchen2", "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -116,7 +123,9 @@ func TestDetails2(t *testing.T) {
 }
 
 func TestPriceText(t *testing.T) {
-	details, err := ScrapeDetails(context.Background(), http.DefaultClient, "com.teslacoilsw.launcher.prime", "in", "en")
+	client := httprecord.Client(t)
+
+	details, err := ScrapeDetails(context.Background(), client, "com.teslacoilsw.launcher.prime", "in", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -126,8 +135,10 @@ func TestPriceText(t *testing.T) {
 }
 
 func TestAvailable(t *testing.T) {
+	client := httprecord.Client(t)
+
 	// BBC News UK is available in the UK...
-	details, err := ScrapeDetails(context.Background(), http.DefaultClient, "bbc.mobile.news.uk", "gb", "en")
+	details, err := ScrapeDetails(context.Background(), client, "bbc.mobile.news.uk", "gb", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -135,7 +146,7 @@ func TestAvailable(t *testing.T) {
 	assert.True(t, details.Available)
 
 	// ...but not in the US
-	details, err = ScrapeDetails(context.Background(), http.DefaultClient, "bbc.mobile.news.uk", "us", "en")
+	details, err = ScrapeDetails(context.Background(), client, "bbc.mobile.news.uk", "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -144,7 +155,9 @@ func TestAvailable(t *testing.T) {
 }
 
 func TestPermissions(t *testing.T) {
-	details, err := ScrapeDetails(context.Background(), http.DefaultClient, "com.google.android.GoogleCamera", "in", "en")
+	client := httprecord.Client(t)
+
+	details, err := ScrapeDetails(context.Background(), client, "com.google.android.GoogleCamera", "in", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
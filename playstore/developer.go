@@ -0,0 +1,213 @@
+package playstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/guregu/null.v4"
+)
+
+type Developer struct {
+	DeveloperId string       `json:"developer_id"`
+	Name        string       `json:"name"`
+	Description null.String  `json:"description"`
+	Website     null.String  `json:"website"`
+	Email       null.String  `json:"email"` // Only filled in by ScrapeDeveloperEmail
+	Icon        null.String  `json:"icon"`
+	HeaderImage null.String  `json:"header_image"`
+	Apps        []SimilarApp `json:"apps"`
+}
+
+var numericDeveloperIdRe = regexp.MustCompile(`^\d+$`)
+
+// Developer pages come in two flavours, depending on the type of developer ID (see
+// developerId). Numeric IDs have a proper developer page with a profile, whereas
+// name-based IDs only have a list of apps. The paths of the apps and of the token for
+// the next page are the ones google-play-scraper uses.
+type developerPage struct {
+	Path      string
+	Numeric   bool
+	AppsPath  string
+	TokenPath string
+}
+
+func newDeveloperPage(developerId string) developerPage {
+	if numericDeveloperIdRe.MatchString(developerId) {
+		return developerPage{
+			Path:      "/store/apps/dev?id=" + developerId,
+			Numeric:   true,
+			AppsPath:  "0.1.0.21.0",
+			TokenPath: "0.1.0.21.1.3.1",
+		}
+	}
+
+	// Name-based IDs are taken straight from the URL, so they are usually escaped already,
+	// but not always properly (see developerId). Unescape them first so that they are not
+	// escaped twice.
+	name, err := url.QueryUnescape(developerId)
+	if err != nil {
+		name = developerId
+	}
+
+	return developerPage{
+		Path:      "/store/apps/developer?id=" + url.QueryEscape(name),
+		Numeric:   false,
+		AppsPath:  "0.1.0.22.0",
+		TokenPath: "0.1.0.22.1.3.1",
+	}
+}
+
+type developerBatchRequester struct {
+	Token string
+}
+
+// Request the next page of a developer's apps. The token comes from the developer
+// page or the previous page of apps.
+func NewDeveloperBatchRequester(token string) BatchRequester[*DeveloperAppsPage] {
+	return &developerBatchRequester{Token: token}
+}
+
+func (br *developerBatchRequester) BatchRequest() batchRequest {
+	token, _ := json.Marshal(br.Token)
+	return batchRequest{
+		RpcId:   "qnKhOb",
+		Payload: fmt.Sprintf(`[[null,[[10,[10,100]],true,null,[96,27,4,8,57,30,110,79,11,16,49,1,3,9,12,104,55,56,51,10,34,77]],null,%s]]`, token),
+	}
+}
+
+// A page of a developer's apps. If NextToken is empty, there are no more apps.
+type DeveloperAppsPage struct {
+	Apps      []SimilarApp
+	NextToken string
+}
+
+func (br *developerBatchRequester) ParseEnvelope(payload string) (*DeveloperAppsPage, error) {
+	if payload == "" {
+		return nil, ErrDeveloperNotFound
+	}
+
+	return parseDeveloperApps(payload, "0.0.0", "0.0.7.1")
+}
+
+func parseDeveloperApps(payload string, appsPath string, tokenPath string) (*DeveloperAppsPage, error) {
+	result := gjson.Get(payload, appsPath)
+
+	if result.Type == gjson.Null {
+		return &DeveloperAppsPage{Apps: []SimilarApp{}}, nil
+	}
+
+	if !result.IsArray() {
+		return nil, fmt.Errorf("wrong type: not array")
+	}
+
+	rawApps := result.Array()
+	apps := make([]SimilarApp, 0, len(rawApps))
+
+	for _, rawApp := range rawApps {
+		extract := NewExtractor(rawApp.Raw)
+		app := extractSimilarApp(extract)
+
+		if len(extract.Errors()) > 0 {
+			return nil, &ExtractError{What: "developer", Errors: extract.Errors(), Payload: payload}
+		}
+
+		apps = append(apps, app)
+	}
+
+	extract := NewExtractor(payload)
+	nextToken := extract.OptionalString(tokenPath)
+	if len(extract.Errors()) > 0 {
+		return nil, &ExtractError{What: "developer", Errors: extract.Errors(), Payload: payload}
+	}
+
+	return &DeveloperAppsPage{Apps: apps, NextToken: nextToken.ValueOrZero()}, nil
+}
+
+// Scrape a developer's profile and all of their apps. Both types of developer ID in
+// Details.DeveloperId are supported, but only developers with numeric IDs have a
+// profile; for the others, only the name is filled in. The developer page does not have
+// the email address, see ScrapeDeveloperEmail.
+func ScrapeDeveloper(ctx context.Context, client *http.Client, developerId string, country string, language string) (*Developer, error) {
+	page := newDeveloperPage(developerId)
+
+	data, err := fetchPageData(ctx, client, BaseUrl+page.Path, country, language)
+	if errors.Is(err, errPageNotFound) {
+		return nil, ErrDeveloperNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payload, ok := data["ds:3"]
+	if !ok {
+		return nil, ErrDeveloperNotFound
+	}
+
+	developer := Developer{DeveloperId: developerId}
+
+	extract := NewExtractor(payload)
+	if page.Numeric {
+		developer.Name = extract.String("0.1.0.21.1.0")
+		developer.Description = extract.OptionalString("0.1.0.21.1.1.1")
+		developer.Website = extract.OptionalString("0.1.0.21.1.2.0.5.2")
+		developer.Icon = extract.OptionalString("0.1.0.21.1.4.3.2")
+		developer.HeaderImage = extract.OptionalString("0.1.0.21.1.5.3.2")
+	} else {
+		developer.Name = extract.String("0.1.0.22.1.0")
+	}
+	if len(extract.Errors()) > 0 {
+		return nil, &ExtractError{What: "developer", Errors: extract.Errors(), Payload: payload}
+	}
+
+	appsPage, err := parseDeveloperApps(payload, page.AppsPath, page.TokenPath)
+	if err != nil {
+		return nil, err
+	}
+	developer.Apps = appsPage.Apps
+
+	// Follow the continuation tokens to get the rest of the apps
+	token := appsPage.NextToken
+	for token != "" {
+		appsPage, err := sendRequest(ctx, client, country, language, NewDeveloperBatchRequester(token))
+		if err != nil {
+			return nil, err
+		}
+
+		developer.Apps = append(developer.Apps, appsPage.Apps...)
+
+		if len(appsPage.Apps) == 0 {
+			break
+		}
+		token = appsPage.NextToken
+	}
+
+	return &developer, nil
+}
+
+// Fill in the email address of a developer scraped with ScrapeDeveloper. The developer
+// page does not have it (the node after the website holds the token for the next page of
+// apps), but the details of the developer's apps do, so this costs a request for the
+// details of the first app. If you already have the details of one of the developer's
+// apps, use Details.DeveloperEmail instead.
+func ScrapeDeveloperEmail(ctx context.Context, client *http.Client, developer *Developer, country string, language string) error {
+	if len(developer.Apps) == 0 {
+		return nil
+	}
+
+	details, err := ScrapeDetails(ctx, client, developer.Apps[0].AppId, country, language)
+	if errors.Is(err, ErrAppNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	developer.Email = details.DeveloperEmail
+	return nil
+}
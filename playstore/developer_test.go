@@ -0,0 +1,94 @@
+package playstore
+
+import (
+	"context"
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+// The email address should be a plain address, not e.g. a JSON array or a token that is
+// at a nearby path
+func assertEmailAddress(t *testing.T, email null.String) {
+	t.Helper()
+
+	if assert.True(t, email.Valid) {
+		address, err := mail.ParseAddress(email.String)
+		if assert.NoError(t, err) {
+			assert.Equal(t, email.String, address.Address)
+		}
+	}
+}
+
+func TestDeveloperNumericId(t *testing.T) {
+	client := httprecord.Client(t)
+
+	developer, err := ScrapeDeveloper(context.Background(), client, "5509190841173705883", "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Jam City, Inc.", developer.Name)
+	assert.True(t, developer.Website.Valid)
+	assert.True(t, developer.HeaderImage.Valid)
+
+	// The email address costs another request, so it is only scraped on demand
+	assert.False(t, developer.Email.Valid)
+	if err := ScrapeDeveloperEmail(context.Background(), client, developer, "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+	assertEmailAddress(t, developer.Email)
+
+	foundPandaPop := false
+	for _, app := range developer.Apps {
+		assert.Equal(t, "Jam City, Inc.", app.Developer)
+		if app.AppId == "com.sgn.pandapop.gp" {
+			foundPandaPop = true
+		}
+	}
+	assert.True(t, foundPandaPop)
+}
+
+func TestDeveloperNameId(t *testing.T) {
+	client := httprecord.Client(t)
+
+	developer, err := ScrapeDeveloper(context.Background(), client, "TeslaCoil+Software", "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "TeslaCoil Software", developer.Name)
+
+	if err := ScrapeDeveloperEmail(context.Background(), client, developer, "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+	assertEmailAddress(t, developer.Email)
+
+	foundNovaPrime := false
+	for _, app := range developer.Apps {
+		if app.AppId == "com.teslacoilsw.launcher.prime" {
+			foundNovaPrime = true
+		}
+	}
+	assert.True(t, foundNovaPrime)
+}
+
+func TestDeveloperPagePath(t *testing.T) {
+	assert.Equal(t, "/store/apps/dev?id=5509190841173705883", newDeveloperPage("5509190841173705883").Path)
+	assert.Equal(t, "/store/apps/developer?id=TeslaCoil+Software", newDeveloperPage("TeslaCoil+Software").Path)
+	assert.Equal(t, "/store/apps/developer?id=TeslaCoil+Software", newDeveloperPage("TeslaCoil Software").Path)
+	assert.Equal(t, "/store/apps/developer?id=Prodev%3B+My+Pro+Apps", newDeveloperPage("Prodev;+My+Pro+Apps").Path)
+	assert.Equal(t, "/store/apps/developer?id=Tom+%26+Jerry", newDeveloperPage("Tom+&+Jerry").Path)
+}
+
+func TestDeveloperNotFound(t *testing.T) {
+	client := httprecord.Client(t)
+
+	developer, err := ScrapeDeveloper(context.Background(), client, "This+Developer+Does+Not+Exist+Hopefully+12345", "us", "en")
+	assert.Nil(t, developer)
+	assert.ErrorIs(t, err, ErrDeveloperNotFound)
+}
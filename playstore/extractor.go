@@ -3,6 +3,7 @@ package playstore
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,16 +11,131 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
+// The payloads can be hundreds of kilobytes and we look up around 60 paths in them, so
+// rather than scanning the whole payload for every path with gjson.Get, the payload is
+// split into a tree of arrays as it is walked. Each array is only scanned once and the
+// paths share the arrays they have in common, e.g. 1.2. See BenchmarkExtractorPaths, but
+// note that it has only been run on the small synthetic payloads of the test fixtures.
+type node struct {
+	result   gjson.Result
+	parsed   bool
+	children []*node
+}
+
+func (n *node) child(i int) *node {
+	if !n.parsed {
+		n.parsed = true
+		if n.result.IsArray() {
+			n.result.ForEach(func(_, value gjson.Result) bool {
+				n.children = append(n.children, &node{result: value})
+				return true
+			})
+		}
+	}
+
+	if i < 0 || i >= len(n.children) {
+		return nil
+	}
+	return n.children[i]
+}
+
+// Look up a gjson-style path, e.g. 1.2.118.#.0.0.2
+func (n *node) get(path string) gjson.Result {
+	current := n
+	for path != "" {
+		var key string
+		if i := strings.IndexByte(path, '.'); i >= 0 {
+			key, path = path[:i], path[i+1:]
+		} else {
+			key, path = path, ""
+		}
+
+		if key == "#" {
+			if !current.result.IsArray() {
+				return gjson.Result{}
+			}
+
+			current.child(0)
+			if path == "" {
+				return gjson.Parse(strconv.Itoa(len(current.children)))
+			}
+
+			// Collect the path from each element of the array into a new array
+			var sb strings.Builder
+			sb.WriteByte('[')
+			first := true
+			for _, c := range current.children {
+				value := c.get(path)
+				if !value.Exists() {
+					continue
+				}
+				if !first {
+					sb.WriteByte(',')
+				}
+				sb.WriteString(value.Raw)
+				first = false
+			}
+			sb.WriteByte(']')
+			return gjson.Parse(sb.String())
+		}
+
+		index, err := strconv.Atoi(key)
+		if err != nil || !current.result.IsArray() {
+			// Not an array index, so let gjson deal with the rest of the path. Like gjson,
+			// a number is a key when looking in an object.
+			rest := key
+			if path != "" {
+				rest += "." + path
+			}
+			return current.result.Get(rest)
+		}
+
+		current = current.child(index)
+		if current == nil {
+			return gjson.Result{}
+		}
+	}
+
+	return current.result
+}
+
 type extractor struct {
-	payload string
-	errors  []error
+	root   *node
+	errors []error
+
+	// Optional, see Monitor
+	monitor *Monitor
 }
 
 func NewExtractor(payload string) *extractor {
 	return &extractor{
-		payload: payload,
-		errors:  nil,
+		root:   &node{result: gjson.Parse(payload)},
+		errors: nil,
+	}
+}
+
+// An extractor for part of the payload. It shares the already parsed parts of the
+// payload, but has its own errors.
+func (e *extractor) Sub(path string) *extractor {
+	current := e.root
+	for _, key := range strings.Split(path, ".") {
+		index, err := strconv.Atoi(key)
+		if err != nil || current == nil || !current.result.IsArray() {
+			sub := NewExtractor(e.root.get(path).Raw)
+			sub.monitor = e.monitor
+			return sub
+		}
+		current = current.child(index)
 	}
+
+	if current == nil {
+		current = &node{}
+	}
+	return &extractor{root: current, monitor: e.monitor}
+}
+
+func (e *extractor) get(path string) gjson.Result {
+	return e.root.get(path)
 }
 
 func (e *extractor) Errors() []error {
@@ -35,12 +151,12 @@ func (e *extractor) error(path string, msg string) {
 }
 
 func (e *extractor) IsNull(path string) bool {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 	return result.Type == gjson.Null
 }
 
 func (e *extractor) Bool(path string) bool {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.False:
@@ -54,7 +170,7 @@ func (e *extractor) Bool(path string) bool {
 }
 
 func (e *extractor) int(path string) (int64, bool) {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	if result.Type != gjson.Number {
 		e.error(path, fmt.Sprintf("wrong type '%s'", result.Type))
@@ -85,7 +201,7 @@ func (e *extractor) Int(path string) int64 {
 }
 
 func (e *extractor) Float(path string) float64 {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.Number:
@@ -97,7 +213,7 @@ func (e *extractor) Float(path string) float64 {
 }
 
 func (e *extractor) String(path string) string {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.String:
@@ -109,7 +225,7 @@ func (e *extractor) String(path string) string {
 }
 
 func (e *extractor) StringSlice(path string) []string {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 	if !result.IsArray() {
 		e.error(path, "is not array")
 		return nil
@@ -131,7 +247,7 @@ func (e *extractor) StringSlice(path string) []string {
 }
 
 func (e *extractor) FloatSlice(path string) []float64 {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 	if !result.IsArray() {
 		e.error(path, "is not array")
 		return nil
@@ -153,7 +269,7 @@ func (e *extractor) FloatSlice(path string) []float64 {
 }
 
 func (e *extractor) OptionalFloatSlice(path string) []null.Float {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 	if !result.IsArray() {
 		e.error(path, "is not array")
 		return nil
@@ -186,11 +302,11 @@ func (e *extractor) Time(path string) time.Time {
 }
 
 func (e *extractor) Json(path string) gjson.Result {
-	return gjson.Get(e.payload, path)
+	return e.get(path)
 }
 
 func (e *extractor) OptionalBool(path string) null.Bool {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.Null:
@@ -206,7 +322,7 @@ func (e *extractor) OptionalBool(path string) null.Bool {
 }
 
 func (e *extractor) OptionalInt(path string) null.Int {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	if result.Type == gjson.Null {
 		return null.Int{}
@@ -221,7 +337,7 @@ func (e *extractor) OptionalInt(path string) null.Int {
 }
 
 func (e *extractor) OptionalFloat(path string) null.Float {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.Null:
@@ -235,7 +351,7 @@ func (e *extractor) OptionalFloat(path string) null.Float {
 }
 
 func (e *extractor) OptionalString(path string) null.String {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	switch result.Type {
 	case gjson.Null:
@@ -249,7 +365,7 @@ func (e *extractor) OptionalString(path string) null.String {
 }
 
 func (e *extractor) OptionalStringSlice(path string) []string {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 
 	if result.Type == gjson.Null {
 		return nil
@@ -279,7 +395,7 @@ func (e *extractor) OptionalStringSlice(path string) []string {
 }
 
 func (e *extractor) OptionalTime(path string) null.Time {
-	result := gjson.Get(e.payload, path)
+	result := e.get(path)
 	if result.Type == gjson.Null {
 		return null.Time{}
 	}
@@ -0,0 +1,129 @@
+package playstore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+// The details payloads in the recorded fixtures of the details tests. The benchmark is
+// skipped if they have not been recorded: the synthetic fixtures only have payloads of a
+// few kilobytes, whereas the ones Google sends are 100KB or more, and the gap between
+// gjson and the extractor grows with the size of the payload, as gjson.Get scans the
+// payload from the start for every path.
+func fixtureDetailsPayloads(tb testing.TB) []string {
+	tb.Helper()
+
+	var payloads []string
+	for _, test := range []string{"TestScrapeDetails", "TestDetails2", "TestPriceText", "TestPermissions"} {
+		interactions, err := httprecord.Load(httprecord.RecordedPath(test))
+		if errors.Is(err, httprecord.ErrNoFixture) {
+			tb.Skipf("%v: record the details fixtures with go test ./playstore -record", err)
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		for _, interaction := range interactions {
+			if !strings.Contains(interaction.Request.Url, "/batchexecute") {
+				continue
+			}
+
+			envelopes, err := respToEnvelopes([]byte(interaction.Response.Body))
+			if err != nil {
+				tb.Fatal(err)
+			}
+
+			for _, envelope := range envelopes {
+				if envelope.RpcId == "Ws7gDc" && envelope.Payload != "" {
+					payloads = append(payloads, envelope.Payload)
+				}
+			}
+		}
+	}
+
+	if len(payloads) == 0 {
+		tb.Fatal("no details payloads in the fixtures")
+	}
+
+	return payloads
+}
+
+func payloadsSize(payloads []string) int64 {
+	var size int64
+	for _, payload := range payloads {
+		size += int64(len(payload))
+	}
+	return size
+}
+
+func TestExtractorGet(t *testing.T) {
+	payload := `[[1,[2,3]],null,[["a",[1]],["b"],["c",[3]]],{"key":[4],"1":[5,[6]]}]`
+	extract := NewExtractor(payload)
+
+	// The tree should give the same results as gjson, including numbers as object keys
+	for _, path := range []string{"0", "0.0", "0.1.1", "0.2", "1", "1.0", "2.#", "2.#.0", "2.#.1.0", "2.5.0", "3.key.0", "0.#", "3.1", "3.1.1.0", "3.0"} {
+		expected := gjson.Get(payload, path)
+		actual := extract.get(path)
+		assert.Equal(t, expected.Type, actual.Type, path)
+		assert.Equal(t, expected.Raw, actual.Raw, path)
+	}
+
+	sub := extract.Sub("2.0")
+	assert.Equal(t, "a", sub.String("0"))
+	assert.Equal(t, int64(1), sub.Int("1.0"))
+	assert.Equal(t, int64(6), extract.Sub("3.1").Int("1.0"))
+	assert.Empty(t, extract.Errors())
+}
+
+// Looking up every path in the payload separately, as we used to
+func BenchmarkGjsonPaths(b *testing.B) {
+	payloads := fixtureDetailsPayloads(b)
+	paths := Paths(&Details{})
+	b.SetBytes(payloadsSize(payloads))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, payload := range payloads {
+			for _, path := range paths {
+				gjson.Get(payload, path.Path)
+			}
+		}
+	}
+}
+
+func BenchmarkExtractorPaths(b *testing.B) {
+	payloads := fixtureDetailsPayloads(b)
+	paths := Paths(&Details{})
+	b.SetBytes(payloadsSize(payloads))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, payload := range payloads {
+			extract := NewExtractor(payload)
+			for _, path := range paths {
+				extract.get(path.Path)
+			}
+		}
+	}
+}
+
+func BenchmarkParseDetails(b *testing.B) {
+	payloads := fixtureDetailsPayloads(b)
+	requester := NewDetailsBatchRequester("com.example.app")
+	b.SetBytes(payloadsSize(payloads))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, payload := range payloads {
+			if _, err := requester.ParseEnvelope(payload); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
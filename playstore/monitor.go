@@ -0,0 +1,172 @@
+package playstore
+
+import (
+	"sort"
+	"sync"
+)
+
+// Google changes the layout of the payloads from time to time without warning. Usually
+// this shows up as extraction errors, but sometimes a path still exists and is just null
+// for every app, e.g. when an optional field moves. A Monitor keeps statistics for every
+// tagged field that is extracted across many requests, so that these silent changes can
+// be spotted during a long scrape rather than after it.
+//
+// Add a Monitor to a Batch to use it. Only the details and similar apps requesters report
+// to the monitor, both for the fields with struct tags and the ones extracted by hand.
+type Monitor struct {
+	// Number of observations of a path that are compared against the ones before them
+	WindowSize int
+
+	// A path is flagged if the null rate of a window is this much higher than the null
+	// rate of all the windows before it
+	Threshold float64
+
+	// Called when a path is flagged. It is called after the monitor has been unlocked, so
+	// it can use the monitor, e.g. to get the Stats.
+	OnDrift func(stats PathStats)
+
+	mu    sync.Mutex
+	paths map[string]*pathMonitor
+}
+
+// Statistics of a path over all the payloads seen by a Monitor
+type PathStats struct {
+	Field string
+	Path  string
+
+	// Number of payloads the path was looked up in
+	Observed int64
+	// The path did not exist, e.g. because an array was too short
+	Missing int64
+	// The value was null
+	Null int64
+	// The value was not null but had the wrong type
+	WrongType int64
+	// The value could not be extracted, including nulls for fields that are not optional
+	Failures int64
+
+	// The null rate (including missing values) of the latest window and of all the
+	// windows before it
+	WindowNullRate   float64
+	BaselineNullRate float64
+	// The null rate jumped at some point
+	Drifted bool
+}
+
+func (s *PathStats) NullRate() float64 {
+	if s.Observed == 0 {
+		return 0
+	}
+	return float64(s.Missing+s.Null) / float64(s.Observed)
+}
+
+type pathMonitor struct {
+	stats PathStats
+
+	windowObserved int64
+	windowNulls    int64
+	baseObserved   int64
+	baseNulls      int64
+}
+
+type pathOutcome int
+
+const (
+	outcomeOk pathOutcome = iota
+	outcomeMissing
+	outcomeNull
+	outcomeWrongType
+)
+
+func NewMonitor() *Monitor {
+	return &Monitor{
+		WindowSize: 500,
+		Threshold:  0.5,
+		paths:      make(map[string]*pathMonitor),
+	}
+}
+
+func (m *Monitor) observe(field string, path string, outcome pathOutcome, failed bool) {
+	if drifted, ok := m.record(field, path, outcome, failed); ok && m.OnDrift != nil {
+		m.OnDrift(drifted)
+	}
+}
+
+// Record an observation of a path. If the path has drifted, its statistics are returned
+// so that OnDrift can be called once the monitor has been unlocked.
+func (m *Monitor) record(field string, path string, outcome pathOutcome, failed bool) (PathStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.paths == nil {
+		m.paths = make(map[string]*pathMonitor)
+	}
+
+	key := field + " " + path
+	p, ok := m.paths[key]
+	if !ok {
+		p = &pathMonitor{stats: PathStats{Field: field, Path: path}}
+		m.paths[key] = p
+	}
+
+	p.stats.Observed++
+	p.windowObserved++
+	switch outcome {
+	case outcomeMissing:
+		p.stats.Missing++
+		p.windowNulls++
+	case outcomeNull:
+		p.stats.Null++
+		p.windowNulls++
+	case outcomeWrongType:
+		p.stats.WrongType++
+	}
+	if failed {
+		p.stats.Failures++
+	}
+
+	if p.windowObserved < int64(m.WindowSize) {
+		return PathStats{}, false
+	}
+
+	// Compare the window that has just finished with the ones before it
+	windowRate := float64(p.windowNulls) / float64(p.windowObserved)
+	p.stats.WindowNullRate = windowRate
+	drifted := false
+	if p.baseObserved > 0 {
+		baseRate := float64(p.baseNulls) / float64(p.baseObserved)
+		p.stats.BaselineNullRate = baseRate
+
+		if windowRate-baseRate > m.Threshold {
+			p.stats.Drifted = true
+			drifted = true
+		}
+	}
+
+	p.baseObserved += p.windowObserved
+	p.baseNulls += p.windowNulls
+	p.windowObserved = 0
+	p.windowNulls = 0
+
+	return p.stats, drifted
+}
+
+// The statistics of all the paths seen so far, sorted by field
+func (m *Monitor) Stats() []PathStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]PathStats, 0, len(m.paths))
+	for _, p := range m.paths {
+		stats = append(stats, p.stats)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Field != stats[j].Field {
+			return stats[i].Field < stats[j].Field
+		}
+		return stats[i].Path < stats[j].Path
+	})
+
+	return stats
+}
@@ -0,0 +1,90 @@
+package playstore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	payload := fixtureDetailsPayloads(t)[0]
+
+	// The same payload, but Google has stopped sending the number of installs
+	var broken []interface{}
+	if err := json.Unmarshal([]byte(payload), &broken); err != nil {
+		t.Fatal(err)
+	}
+	broken[1].([]interface{})[2].([]interface{})[13] = nil
+	brokenPayload, err := json.Marshal(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := NewMonitor()
+	monitor.WindowSize = 10
+
+	var drifted []string
+	monitor.OnDrift = func(stats PathStats) {
+		drifted = append(drifted, stats.Field)
+
+		// The monitor can be used from the callback
+		assert.NotEmpty(t, monitor.Stats())
+	}
+
+	requester := &detailsBatchRequester{AppId: "com.example.app"}
+	for i := 0; i < 10; i++ {
+		_, err := requester.parseEnvelope(payload, monitor)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		_, err := requester.parseEnvelope(string(brokenPayload), monitor)
+		assert.NoError(t, err)
+	}
+
+	assert.ElementsMatch(t, []string{"Details.Installs", "Details.MinInstalls", "Details.MaxInstalls"}, drifted)
+
+	byField := make(map[string]PathStats)
+	for _, stats := range monitor.Stats() {
+		byField[stats.Field] = stats
+	}
+
+	installs := byField["Details.Installs"]
+	assert.Equal(t, "1.2.13.0", installs.Path)
+	assert.Equal(t, int64(20), installs.Observed)
+	assert.Equal(t, int64(10), installs.Missing)
+	assert.Equal(t, int64(0), installs.Failures)
+	assert.Equal(t, 0.5, installs.NullRate())
+	assert.True(t, installs.Drifted)
+
+	title := byField["Details.Title"]
+	assert.Equal(t, int64(20), title.Observed)
+	assert.False(t, title.Drifted)
+
+	// The fields that are extracted by hand are monitored too
+	for _, field := range []string{"Details.Available", "Details.DeveloperId", "Details.AdSupported", "Details.Permissions"} {
+		assert.Equal(t, int64(20), byField[field].Observed, field)
+	}
+	assert.Equal(t, "1.2.42.0", byField["Details.Available"].Path)
+}
+
+func TestMonitorWrongType(t *testing.T) {
+	monitor := NewMonitor()
+
+	extract := NewExtractor(`["not a number",null]`)
+	extract.monitor = monitor
+
+	var v struct {
+		Count int64  `playstore:"0"`
+		Name  string `playstore:"1"`
+	}
+	extract.Decode(&v)
+
+	stats := monitor.Stats()
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, int64(1), stats[0].WrongType)
+		assert.Equal(t, int64(1), stats[0].Failures)
+		assert.Equal(t, int64(1), stats[1].Null)
+		assert.Equal(t, int64(1), stats[1].Failures)
+	}
+}
@@ -0,0 +1,139 @@
+package playstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/guregu/null.v4"
+)
+
+// The maximum number of reviews that the Play Store will return in one request
+const maxReviewsPerRequest = 199
+
+type ReviewSort int
+
+const (
+	SortMostRelevant ReviewSort = 1
+	SortNewest       ReviewSort = 2
+	SortRating       ReviewSort = 3
+)
+
+type Review struct {
+	ReviewId    string      `json:"review_id" playstore:"0"`
+	Author      string      `json:"author" playstore:"1.0"`
+	AuthorImage null.String `json:"author_image" playstore:"1.1.3.2"`
+	Rating      int64       `json:"rating" playstore:"2"`
+	Text        null.String `json:"text" playstore:"4"`
+	ThumbsUp    int64       `json:"thumbs_up" playstore:"6,optional"`
+	AppVersion  null.String `json:"app_version" playstore:"10"`
+	Time        time.Time   `json:"time" playstore:"5.0"`
+	Reply       null.String `json:"reply" playstore:"7.1"`
+	ReplyTime   null.Time   `json:"reply_time" playstore:"7.2.0"`
+}
+
+// One page of reviews. If NextToken is empty, there are no more reviews.
+type ReviewsPage struct {
+	Reviews   []Review
+	NextToken string
+}
+
+type reviewsBatchRequester struct {
+	AppId string
+	Sort  ReviewSort
+	Count int
+	Token string
+}
+
+// Request up to count reviews for an app. To get the following page, pass the
+// NextToken of the previous page as token, otherwise use the empty string.
+func NewReviewsBatchRequester(appId string, sort ReviewSort, count int, token string) BatchRequester[*ReviewsPage] {
+	return &reviewsBatchRequester{AppId: appId, Sort: sort, Count: count, Token: token}
+}
+
+func (br *reviewsBatchRequester) BatchRequest() batchRequest {
+	appId, _ := json.Marshal(br.AppId)
+
+	token := "null"
+	if br.Token != "" {
+		t, _ := json.Marshal(br.Token)
+		token = string(t)
+	}
+
+	return batchRequest{
+		RpcId:   "UsvDTd",
+		Payload: fmt.Sprintf(`[null,null,[2,%d,[%d,null,%s],null,[]],[%s,7]]`, br.Sort, br.Count, token, appId),
+	}
+}
+
+func (br *reviewsBatchRequester) ParseEnvelope(payload string) (*ReviewsPage, error) {
+	if payload == "" {
+		return nil, ErrAppNotFound
+	}
+
+	result := gjson.Get(payload, "0")
+
+	if result.Type == gjson.Null {
+		// There are no reviews
+		return &ReviewsPage{Reviews: []Review{}}, nil
+	}
+
+	if !result.IsArray() {
+		return nil, fmt.Errorf("wrong type: not array")
+	}
+
+	rawReviews := result.Array()
+	reviews := make([]Review, 0, len(rawReviews))
+
+	for _, rawReview := range rawReviews {
+		extract := NewExtractor(rawReview.Raw)
+
+		var review Review
+		extract.Decode(&review)
+
+		if len(extract.Errors()) > 0 {
+			return nil, &ExtractError{What: "reviews", Errors: extract.Errors(), Payload: payload}
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	extract := NewExtractor(payload)
+	nextToken := extract.OptionalString("1.1")
+	if len(extract.Errors()) > 0 {
+		return nil, &ExtractError{What: "reviews", Errors: extract.Errors(), Payload: payload}
+	}
+
+	return &ReviewsPage{Reviews: reviews, NextToken: nextToken.ValueOrZero()}, nil
+}
+
+// Scrape the reviews of an app, following the continuation tokens until count reviews
+// have been scraped. If count is zero or negative, then all the reviews are scraped.
+func ScrapeReviews(ctx context.Context, client *http.Client, appId string, country string, language string, sort ReviewSort, count int) ([]Review, error) {
+	reviews := []Review{}
+	token := ""
+
+	for count <= 0 || len(reviews) < count {
+		n := maxReviewsPerRequest
+		if count > 0 && count-len(reviews) < n {
+			n = count - len(reviews)
+		}
+
+		reviewsPage, err := sendRequest(ctx, client, country, language, NewReviewsBatchRequester(appId, sort, n, token))
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, reviewsPage.Reviews...)
+
+		if reviewsPage.NextToken == "" || len(reviewsPage.Reviews) == 0 {
+			break
+		}
+		token = reviewsPage.NextToken
+	}
+
+	return reviews, nil
+}
@@ -0,0 +1,61 @@
+package playstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestReviews(t *testing.T) {
+	client := httprecord.Client(t)
+
+	reviews, err := ScrapeReviews(context.Background(), client, "com.sgn.pandapop.gp", "us", "en", SortNewest, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More than one page of reviews should have been scraped
+	assert.Len(t, reviews, 250)
+
+	seen := make(map[string]bool)
+	for i, review := range reviews {
+		assert.NotEmpty(t, review.ReviewId)
+		assert.NotEmpty(t, review.Author)
+		assert.GreaterOrEqual(t, review.Rating, int64(1))
+		assert.LessOrEqual(t, review.Rating, int64(5))
+		assert.False(t, review.Time.IsZero())
+		assert.False(t, seen[review.ReviewId], "duplicate review")
+		seen[review.ReviewId] = true
+
+		// Sorted by newest first
+		if i > 0 {
+			assert.False(t, review.Time.After(reviews[i-1].Time))
+		}
+	}
+}
+
+func TestReviewsSortRating(t *testing.T) {
+	client := httprecord.Client(t)
+
+	reviews, err := ScrapeReviews(context.Background(), client, "com.sgn.pandapop.gp", "us", "en", SortRating, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, reviews, 50)
+}
+
+func TestReviewsNotFound(t *testing.T) {
+	client := httprecord.Client(t)
+
+	reviews, err := ScrapeReviews(context.Background(), client, nonExistentAppId, "us", "en", SortNewest, 10)
+	if err != nil && err != ErrAppNotFound {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, err, ErrAppNotFound)
+	assert.Len(t, reviews, 0)
+}
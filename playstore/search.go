@@ -0,0 +1,126 @@
+package playstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/guregu/null.v4"
+)
+
+type SearchResult struct {
+	AppId     string      `json:"app_id" playstore:"12.0"`
+	Title     string      `json:"title" playstore:"2"`
+	Summary   null.String `json:"summary" playstore:"4.1.1.1.1"`
+	Developer string      `json:"developer" playstore:"4.0.0.0"`
+	Icon      null.String `json:"icon" playstore:"1.1.0.3.2"`
+	Score     null.Float  `json:"score" playstore:"6.0.2.1.1"`
+	ScoreText null.String `json:"score_text" playstore:"6.0.2.1.0"`
+	Price     null.Float  `json:"price" playstore:"7.0.3.2.1.0.0,micros"`
+	Currency  null.String `json:"currency" playstore:"7.0.3.2.1.0.1"`
+}
+
+// One page of search results. If NextToken is empty, there are no more results.
+type SearchPage struct {
+	Results   []SearchResult
+	NextToken string
+}
+
+type searchBatchRequester struct {
+	Query string
+	Token string
+}
+
+// Search for apps. To get the following page of results, pass the NextToken of the
+// previous page as token, otherwise use the empty string.
+func NewSearchBatchRequester(query string, token string) BatchRequester[*SearchPage] {
+	return &searchBatchRequester{Query: query, Token: token}
+}
+
+func (br *searchBatchRequester) BatchRequest() batchRequest {
+	// The first page and the following pages use different RPCs
+	if br.Token == "" {
+		query, _ := json.Marshal(br.Query)
+		return batchRequest{
+			RpcId:   "lGYRle",
+			Payload: fmt.Sprintf(`[[[],[[8,[20,50]],true,null,[96,27,4,8,57,30,110,79,11,16,49,1,3,9,12,104,55,56,51,10,34,77]],[%s],4,null,null,null,[]]]`, query),
+		}
+	}
+
+	token, _ := json.Marshal(br.Token)
+	return batchRequest{
+		RpcId:   "qnKhOb",
+		Payload: fmt.Sprintf(`[[null,[[10,[10,50]],true,null,[96,27,4,8,57,30,110,79,11,16,49,1,3,9,12,104,55,56,51,10,34,77]],null,%s]]`, token),
+	}
+}
+
+func (br *searchBatchRequester) ParseEnvelope(payload string) (*SearchPage, error) {
+	if payload == "" {
+		return &SearchPage{Results: []SearchResult{}}, nil
+	}
+
+	resultsPath, tokenPath := "0.1.0.0.0", "0.1.0.0.7.1"
+	if br.Token != "" {
+		resultsPath, tokenPath = "0.0.0", "0.0.7.1"
+	}
+
+	result := gjson.Get(payload, resultsPath)
+
+	if result.Type == gjson.Null {
+		// There are no search results
+		return &SearchPage{Results: []SearchResult{}}, nil
+	}
+
+	if !result.IsArray() {
+		return nil, fmt.Errorf("wrong type: not array")
+	}
+
+	rawResults := result.Array()
+	searchResults := make([]SearchResult, 0, len(rawResults))
+
+	for _, rawResult := range rawResults {
+		extract := NewExtractor(rawResult.Raw)
+
+		var searchResult SearchResult
+		extract.Decode(&searchResult)
+
+		if len(extract.Errors()) > 0 {
+			return nil, &ExtractError{What: "search results", Errors: extract.Errors(), Payload: payload}
+		}
+
+		searchResults = append(searchResults, searchResult)
+	}
+
+	extract := NewExtractor(payload)
+	nextToken := extract.OptionalString(tokenPath)
+	if len(extract.Errors()) > 0 {
+		return nil, &ExtractError{What: "search results", Errors: extract.Errors(), Payload: payload}
+	}
+
+	return &SearchPage{Results: searchResults, NextToken: nextToken.ValueOrZero()}, nil
+}
+
+// Search the Play Store for apps, following the continuation tokens until there are
+// no more results.
+func Search(ctx context.Context, client *http.Client, query string, country string, language string) ([]SearchResult, error) {
+	results := []SearchResult{}
+	token := ""
+
+	for {
+		searchPage, err := sendRequest(ctx, client, country, language, NewSearchBatchRequester(query, token))
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, searchPage.Results...)
+
+		if searchPage.NextToken == "" || len(searchPage.Results) == 0 {
+			break
+		}
+		token = searchPage.NextToken
+	}
+
+	return results, nil
+}
@@ -0,0 +1,47 @@
+package playstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestSearch(t *testing.T) {
+	client := httprecord.Client(t)
+
+	results, err := Search(context.Background(), client, "email", "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foundOutlook := false
+	seen := make(map[string]bool)
+
+	for _, result := range results {
+		assert.False(t, seen[result.AppId], "duplicate search result")
+		seen[result.AppId] = true
+
+		if result.AppId == "com.microsoft.office.outlook" {
+			foundOutlook = true
+			assert.Equal(t, "Microsoft Corporation", result.Developer)
+		}
+	}
+
+	// There should be more than one page of results
+	assert.Greater(t, len(results), 50)
+	assert.True(t, foundOutlook)
+}
+
+func TestSearchNoResults(t *testing.T) {
+	client := httprecord.Client(t)
+
+	results, err := Search(context.Background(), client, "qwxzqwxzqwxzqwxzqwxz", "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, results, 0)
+}
@@ -0,0 +1,140 @@
+package playstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How long to use a session before getting a new one
+const sessionMaxAge = 30 * time.Minute
+
+var (
+	sessionIdRe  = regexp.MustCompile(`"FdrFJe":"(-?\d+)"`)
+	buildLabelRe = regexp.MustCompile(`"cfb2h":"([^"]+)"`)
+)
+
+// The Play Store web client identifies itself to batchexecute with a session ID (f.sid),
+// the build label of the web app (bl) and a request counter (_reqid). We get the first
+// two from the Play Store website and count requests like the web client does, so that
+// our requests look like they come from the web client.
+type session struct {
+	mu        sync.Mutex
+	sessionId string
+	label     string
+	requestId int
+	created   time.Time
+}
+
+// There is one session for each HTTP client and Play Store address, so that the
+// exported functions can keep taking an *http.Client, and sessions are not shared between
+// unrelated clients. The sessions are small and clients usually live as long as the
+// program, so they are kept for the life of the program.
+var sessions = struct {
+	sync.Mutex
+	m map[sessionKey]*session
+}{m: make(map[sessionKey]*session)}
+
+type sessionKey struct {
+	client  *http.Client
+	baseUrl string
+}
+
+// The session of the HTTP client for the current BaseUrl. Only the lookup holds the lock
+// of the cache, so one client's refresh does not hold up the requests of other clients.
+func getSession(client *http.Client) *session {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	key := sessionKey{client: client, baseUrl: BaseUrl}
+	s, ok := sessions.m[key]
+	if !ok {
+		s = &session{}
+		sessions.m[key] = s
+	}
+
+	return s
+}
+
+// The query parameters for the next request, refreshing the session if it has expired.
+// Other requests of the same client wait for the refresh, as they need the new session
+// too, but the requests of other clients do not, as each client has its own session.
+func (s *session) params(ctx context.Context, client *http.Client) (url.Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.created.IsZero() || time.Since(s.created) > sessionMaxAge {
+		if err := s.refresh(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	// The web client starts from a random four digit number and adds 100000 each time
+	s.requestId += 100000
+
+	params := url.Values{}
+	if s.sessionId != "" {
+		params.Set("f.sid", s.sessionId)
+	}
+	if s.label != "" {
+		params.Set("bl", s.label)
+	}
+	params.Set("_reqid", strconv.Itoa(s.requestId))
+
+	return params, nil
+}
+
+// Get a new session the next time a request is made
+func (s *session) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.created = time.Time{}
+}
+
+func (s *session) refresh(ctx context.Context, client *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", BaseUrl+"/store/apps", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting a session: %s", resp.Status)
+	}
+
+	// If Google changes the page, carry on without the session parameters rather than
+	// failing: batchexecute still accepts requests without them.
+	s.sessionId, s.label = "", ""
+	if matches := sessionIdRe.FindSubmatch(body); matches != nil {
+		s.sessionId = string(matches[1])
+	}
+	if matches := buildLabelRe.FindSubmatch(body); matches != nil {
+		s.label = string(matches[1])
+	}
+
+	s.requestId = 1000 + rand.Intn(9000)
+	s.created = time.Now()
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package playstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	const page = `<script>window.WIZ_global_data = {"FdrFJe":"-1234567890","cfb2h":"boq_playuiserver_20220505.04_p0"};</script>`
+
+	var sessionRequests int
+	var requestIds []int
+	rejectNext := false
+
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "GET" {
+				sessionRequests++
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(page)), Request: req}, nil
+			}
+
+			q := req.URL.Query()
+			assert.Equal(t, "-1234567890", q.Get("f.sid"))
+			assert.Equal(t, "boq_playuiserver_20220505.04_p0", q.Get("bl"))
+
+			requestId, err := strconv.Atoi(q.Get("_reqid"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			requestIds = append(requestIds, requestId)
+
+			if rejectNext {
+				rejectNext = false
+				return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+
+			body := `)]}'` + "\n\n" + `[["wrb.fr","ag2B9c","[]",null,null,null,"0"]]`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		}),
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := ScrapeSimilar(context.Background(), client, "com.example.app", "us", "en"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The session is reused and the request ID goes up like the web client's
+	assert.Equal(t, 1, sessionRequests)
+	assert.Len(t, requestIds, 3)
+	assert.Equal(t, requestIds[0]+100000, requestIds[1])
+	assert.Equal(t, requestIds[1]+100000, requestIds[2])
+
+	// An expired session is refreshed and the request retried
+	rejectNext = true
+	if _, err := ScrapeSimilar(context.Background(), client, "com.example.app", "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, sessionRequests)
+	assert.Len(t, requestIds, 5)
+
+	// Another HTTP client has a session of its own, even with the same transport
+	other := &http.Client{Transport: client.Transport}
+	if _, err := ScrapeSimilar(context.Background(), other, "com.example.app", "us", "en"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, sessionRequests)
+}
+
+func TestSessionError(t *testing.T) {
+	var batchRequests int
+
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "GET" {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}
+
+			batchRequests++
+			body := `)]}'` + "\n\n" + `[["wrb.fr","ag2B9c","[]",null,null,null,"0"]]`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		}),
+	}
+
+	// Without a session, the request is not sent
+	_, err := ScrapeSimilar(context.Background(), client, "com.example.app", "us", "en")
+	assert.ErrorContains(t, err, "503 Service Unavailable")
+	assert.Equal(t, 0, batchRequests)
+}
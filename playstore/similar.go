@@ -4,43 +4,29 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/tidwall/gjson"
 	"gopkg.in/guregu/null.v4"
 )
 
 type SimilarApp struct {
-	AppId     string      `json:"app_id"`
-	Title     string      `json:"title"`
-	Developer string      `json:"developer"`
-	Score     null.Float  `json:"score"`
-	ScoreText null.String `json:"score_text"`
-	Price     null.Float  `json:"price"`
-	Currency  null.String `json:"currency"`
+	AppId     string      `json:"app_id" playstore:"0.0"`
+	Title     string      `json:"title" playstore:"3"`
+	Developer string      `json:"developer" playstore:"14"`
+	Score     null.Float  `json:"score" playstore:"4.1"`
+	ScoreText null.String `json:"score_text" playstore:"4.0"`
+	Price     null.Float  `json:"price" playstore:"8.1.0.0,micros"`
+	Currency  null.String `json:"currency" playstore:"8.1.0.1"`
 }
 
-type SimilarAppsExtractError struct {
-	Errors  []error
-	Payload string
-}
-
-func (e *SimilarAppsExtractError) Error() string {
-	sb := strings.Builder{}
-
-	sb.WriteString("Error extracting similar apps:\n")
-	for _, err := range e.Errors {
-		sb.WriteString(fmt.Sprintf("\t- %s\n", err.Error()))
-	}
-
-	return sb.String()
-}
+// Deprecated: similar apps are extracted into an ExtractError, like everything else.
+type SimilarAppsExtractError = ExtractError
 
 type similarBatchRequester struct {
 	AppId string
 }
 
-func NewSimilarBatchRequester(appId string) *similarBatchRequester {
+func NewSimilarBatchRequester(appId string) BatchRequester[[]SimilarApp] {
 	return &similarBatchRequester{AppId: appId}
 }
 
@@ -51,7 +37,11 @@ func (br *similarBatchRequester) BatchRequest() batchRequest {
 	}
 }
 
-func (br *similarBatchRequester) ParseEnvelope(payload string) (interface{}, error) {
+func (br *similarBatchRequester) ParseEnvelope(payload string) ([]SimilarApp, error) {
+	return br.parseEnvelope(payload, nil)
+}
+
+func (br *similarBatchRequester) parseEnvelope(payload string, monitor *Monitor) ([]SimilarApp, error) {
 	if payload == "" {
 		return nil, ErrAppNotFound
 	}
@@ -72,19 +62,11 @@ func (br *similarBatchRequester) ParseEnvelope(payload string) (interface{}, err
 
 	for _, rawApp := range rawApps {
 		extract := NewExtractor(rawApp.Raw)
-
-		similarApp := SimilarApp{
-			AppId:     extract.String("0.0"),
-			Title:     extract.String("3"),
-			Developer: extract.String("14"),
-			Score:     extract.OptionalFloat("4.1"),
-			ScoreText: extract.OptionalString("4.0"),
-			Price:     maybePrice(extract.OptionalFloat("8.1.0.0")),
-			Currency:  extract.OptionalString("8.1.0.1"),
-		}
+		extract.monitor = monitor
+		similarApp := extractSimilarApp(extract)
 
 		if len(extract.Errors()) > 0 {
-			return nil, &SimilarAppsExtractError{Errors: extract.Errors(), Payload: payload}
+			return nil, &ExtractError{What: "similar apps", Errors: extract.Errors(), Payload: payload}
 		}
 
 		similarApps = append(similarApps, similarApp)
@@ -93,22 +75,14 @@ func (br *similarBatchRequester) ParseEnvelope(payload string) (interface{}, err
 	return similarApps, nil
 }
 
-func ScrapeSimilar(ctx context.Context, client *http.Client, appId string, country string, language string) ([]SimilarApp, error) {
-	requester := NewSimilarBatchRequester(appId)
-	envelopes, err := sendRequests(ctx, client, country, language, []BatchRequester{requester})
-	if err != nil {
-		return nil, err
-	}
-
-	if len(envelopes) == 0 {
-		return nil, fmt.Errorf("no envelope")
-	}
-	envelope := envelopes[0]
-
-	similar, err := requester.ParseEnvelope(envelope.Payload)
-	if err != nil {
-		return nil, err
-	}
+// The Play Store uses the same layout for lists of apps in several places, e.g. similar
+// apps and the apps on a developer's page.
+func extractSimilarApp(extract *extractor) SimilarApp {
+	var similarApp SimilarApp
+	extract.Decode(&similarApp)
+	return similarApp
+}
 
-	return similar.([]SimilarApp), nil
+func ScrapeSimilar(ctx context.Context, client *http.Client, appId string, country string, language string) ([]SimilarApp, error) {
+	return sendRequest(ctx, client, country, language, NewSimilarBatchRequester(appId))
 }
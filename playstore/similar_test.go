@@ -2,15 +2,18 @@ package playstore
 
 import (
 	"context"
-	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v4"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
 )
 
 func TestSimilar(t *testing.T) {
-	similarApps, err := ScrapeSimilar(context.Background(), http.DefaultClient, "com.microsoft.office.outlook", "us", "en")
+	client := httprecord.Client(t)
+
+	similarApps, err := ScrapeSimilar(context.Background(), client, "com.microsoft.office.outlook", "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -38,7 +41,9 @@ func TestSimilar(t *testing.T) {
 }
 
 func TestSimilarPaid(t *testing.T) {
-	similarApps, err := ScrapeSimilar(context.Background(), http.DefaultClient, "com.tocaboca.tocahospital", "us", "en")
+	client := httprecord.Client(t)
+
+	similarApps, err := ScrapeSimilar(context.Background(), client, "com.tocaboca.tocahospital", "us", "en")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -53,7 +58,9 @@ func TestSimilarPaid(t *testing.T) {
 }
 
 func TestSimilarNotFound(t *testing.T) {
-	similarApps, err := ScrapeSimilar(context.Background(), http.DefaultClient, nonExistentAppId, "us", "en")
+	client := httprecord.Client(t)
+
+	similarApps, err := ScrapeSimilar(context.Background(), client, nonExistentAppId, "us", "en")
 	if err != nil && err != ErrAppNotFound {
 		t.Fatal(err)
 	}
@@ -0,0 +1,97 @@
+package playstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+)
+
+// The Play Store shows at most this many apps in a chart
+const maxChartLength = 200
+
+type Chart string
+
+const (
+	ChartTopFree     Chart = "topselling_free"
+	ChartTopPaid     Chart = "topselling_paid"
+	ChartTopGrossing Chart = "topgrossing"
+)
+
+// Category IDs are the same as in Details.Genre and Details.AdditionalGenres, e.g.
+// "GAME_PUZZLE". There are also categories covering all apps and all games.
+const (
+	CategoryAllApps  = "APPLICATION"
+	CategoryAllGames = "GAME"
+)
+
+type ChartEntry struct {
+	// Ranks start from 1
+	Rank int `json:"rank"`
+	SimilarApp
+}
+
+type topChartBatchRequester struct {
+	Chart    Chart
+	Category string
+}
+
+func NewTopChartBatchRequester(chart Chart, category string) BatchRequester[[]ChartEntry] {
+	return &topChartBatchRequester{Chart: chart, Category: category}
+}
+
+func (br *topChartBatchRequester) BatchRequest() batchRequest {
+	chart, _ := json.Marshal(br.Chart)
+	category, _ := json.Marshal(br.Category)
+
+	return batchRequest{
+		RpcId:   "vyAe2",
+		Payload: fmt.Sprintf(`[[null,[[8,[20,%d]],true,null,[96,27,4,8,57,30,110,79,11,16,49,1,3,9,12,104,55,56,51,10,34,77]],null,null,null,null,null,null,null,[[[%s],%s]]]]`, maxChartLength, chart, category),
+	}
+}
+
+func (br *topChartBatchRequester) ParseEnvelope(payload string) ([]ChartEntry, error) {
+	if payload == "" {
+		return nil, fmt.Errorf("chart not found: %s/%s", br.Category, br.Chart)
+	}
+
+	result := gjson.Get(payload, "0.1.0.28.0")
+
+	if result.Type == gjson.Null {
+		// The chart is empty
+		return []ChartEntry{}, nil
+	}
+
+	if !result.IsArray() {
+		return nil, fmt.Errorf("wrong type: not array")
+	}
+
+	rawApps := result.Array()
+	entries := make([]ChartEntry, 0, len(rawApps))
+
+	for i, rawApp := range rawApps {
+		// Each app is wrapped in an extra array compared to the similar apps
+		extract := NewExtractor(rawApp.Get("0").Raw)
+
+		entry := ChartEntry{
+			Rank:       i + 1,
+			SimilarApp: extractSimilarApp(extract),
+		}
+
+		if len(extract.Errors()) > 0 {
+			return nil, &ExtractError{What: "chart", Errors: extract.Errors(), Payload: payload}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Scrape a top chart (top free, top paid or top grossing) for a category. The entries
+// are returned in rank order.
+func ScrapeTopChart(ctx context.Context, client *http.Client, chart Chart, category string, country string, language string) ([]ChartEntry, error) {
+	return sendRequest(ctx, client, country, language, NewTopChartBatchRequester(chart, category))
+}
@@ -0,0 +1,41 @@
+package playstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Price-of-Privacy-in-Digital-Markets/app-scraping/internal/httprecord"
+)
+
+func TestTopChartFree(t *testing.T) {
+	client := httprecord.Client(t)
+
+	entries, err := ScrapeTopChart(context.Background(), client, ChartTopFree, CategoryAllApps, "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, entries)
+	for i, entry := range entries {
+		assert.Equal(t, i+1, entry.Rank)
+		assert.NotEmpty(t, entry.AppId)
+		assert.Equal(t, 0.0, entry.Price.ValueOrZero())
+	}
+}
+
+func TestTopChartPaidCategory(t *testing.T) {
+	client := httprecord.Client(t)
+
+	entries, err := ScrapeTopChart(context.Background(), client, ChartTopPaid, "GAME_PUZZLE", "us", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Positive(t, entry.Price.ValueOrZero())
+		assert.Equal(t, "USD", entry.Currency.ValueOrZero())
+	}
+}
@@ -2,13 +2,42 @@ package playstore
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 )
 
+// The address of the Play Store
+const DefaultBaseUrl = "https://play.google.com"
+
+// The address that the scrapers send their requests to. It can be changed to point them
+// at a different server, e.g. a fake one for testing, but not while requests are being
+// made.
+var BaseUrl = DefaultBaseUrl
+
 var ErrAppNotFound error = errors.New("app not found")
+var ErrDeveloperNotFound error = errors.New("developer not found")
+
+// Some fields could not be extracted from a payload. What says what was being extracted,
+// e.g. "reviews".
+type ExtractError struct {
+	What    string
+	Errors  []error
+	Payload string
+}
+
+func (e *ExtractError) Error() string {
+	sb := strings.Builder{}
+
+	sb.WriteString(fmt.Sprintf("Error extracting %s:\n", e.What))
+	for _, err := range e.Errors {
+		sb.WriteString(fmt.Sprintf("\t- %s\n", err.Error()))
+	}
+
+	return sb.String()
+}
 
 func textFromHTML(description string) (string, error) {
 	fragment, err := html.ParseFragment(strings.NewReader(description), nil)
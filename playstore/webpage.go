@@ -0,0 +1,77 @@
+package playstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+)
+
+var errPageNotFound = errors.New("page not found")
+
+var initDataCallbackRe = regexp.MustCompile(`(?s)AF_initDataCallback\(\{key: '(ds:\d+)', hash: '\d+', data:(.*?), sideChannel: \{\}\}\);</script>`)
+
+// Some things (e.g. developer pages) are easier to get from the Play Store website than
+// through batchexecute. The website embeds the data used to render the page in
+// AF_initDataCallback calls, which have the same format as the batchexecute payloads.
+// The data is returned keyed by the callback key, e.g. "ds:3".
+func fetchPageData(ctx context.Context, client *http.Client, pageUrl string, country string, language string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't use req.URL.Query() because it drops parameters containing semicolons, which
+	// are allowed in developer IDs
+	params := url.Values{}
+	params.Set("hl", language)
+	params.Set("gl", country)
+	if req.URL.RawQuery == "" {
+		req.URL.RawQuery = params.Encode()
+	} else {
+		req.URL.RawQuery += "&" + params.Encode()
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errPageNotFound
+	case http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, fmt.Errorf("fetching %s: %s", pageUrl, resp.Status)
+	}
+
+	data := make(map[string]string)
+	for _, matches := range initDataCallbackRe.FindAllSubmatch(body, -1) {
+		payload := bytes.TrimSpace(matches[2])
+		if !gjson.ValidBytes(payload) {
+			return nil, fmt.Errorf("%s has invalid JSON payload", matches[1])
+		}
+		data[string(matches[1])] = string(payload)
+	}
+
+	if len(data) == 0 {
+		// Probably a captcha page rather than the page we asked for
+		return nil, ErrRateLimited
+	}
+
+	return data, nil
+}